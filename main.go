@@ -2,19 +2,80 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	dsync "github.com/bondhan/sync/modules"
 	"github.com/bondhan/sync/modules/errors"
+	"github.com/bondhan/sync/modules/logger"
+	"github.com/bondhan/sync/modules/progress"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
+// patternList collects the values of a repeatable flag
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// exit codes, modeled after rsync where a matching case exists
+const (
+	exitOK               = 0
+	exitError            = 1  // any other error
+	exitInvalidUsage     = 2  // wrong flags or arguments
+	exitSourceUnreadable = 3  // the source folder cannot be read
+	exitDestinationFull  = 4  // no space left in destination
+	exitCanceled         = 20 // interrupted by a signal
+	exitPartialTransfer  = 23 // some files could not be synced
+)
+
+// exitCode will map err to the exit code of its class
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, dsyncerr.ErrSyncCanceled):
+		return exitCanceled
+	case errors.Is(err, dsyncerr.ErrDestinationFull):
+		return exitDestinationFull
+	case errors.Is(err, dsyncerr.ErrSourceUnreadable):
+		return exitSourceUnreadable
+	case errors.Is(err, dsyncerr.ErrInvalidUsage):
+		return exitInvalidUsage
+	case errors.Is(err, dsyncerr.ErrPartialTransfer):
+		return exitPartialTransfer
+	}
+	return exitError
+}
+
+// usageErr will mark a missing source or destination folder as an invalid usage
+func usageErr(err error) error {
+	if os.IsNotExist(err) {
+		return dsyncerr.Wrap(dsyncerr.ErrInvalidUsage, err)
+	}
+	return err
+}
+
 func checkErr(err error) {
+	exitOnErr(os.Stdout, err)
+}
+
+// exitOnErr will print err to w and exit with the code of its class
+func exitOnErr(w io.Writer, err error) {
 	if err != nil {
-		fmt.Println("Err:", err)
-		os.Exit(1)
+		_, _ = fmt.Fprintln(w, "Err:", err)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -44,31 +105,194 @@ func isDir(path string) (bool, error) {
 	return true, nil
 }
 
+// newLogger will build the logger writing to stderr, without a level nothing is
+// logged unless verbose is set, which logs everything
+func newLogger(w io.Writer, format, level string, isVerbose bool) (dsynclog.Logger, error) {
+	if level == "" {
+		if !isVerbose {
+			return dsynclog.Nop(), nil
+		}
+		level = dsynclog.LevelDebug.String()
+	}
+	lvl, err := dsynclog.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "text":
+		return dsynclog.NewText(w, lvl), nil
+	case "json":
+		return dsynclog.NewJSON(w, lvl), nil
+	}
+	return nil, dsynclog.ErrUnknownFormat
+}
+
+// writeReport will write the run report as JSON to the file, or to stdout when no file is given
+func writeReport(report *dsync.Report, fileName string) error {
+	if fileName == "" || fileName == "-" {
+		return report.WriteJSON(os.Stdout)
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err = report.WriteJSON(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 func main() {
 	var src, dest string
-	var isVerbose, createEmptyFolder bool
+	var isVerbose, createEmptyFolder, isDelete, isDryRun, isFsync bool
+	var preservePerms, preserveTimes, isArchive, useGitignore, showProgress, hardLinks, isSparse, useDelta, resume bool
+	var compare, checksumAlgo, logFormat, logLevel, reportFormat, reportFile, onError, symlinks string
+	var maxErrors, retries int
+	var retryBackoff, retryMaxBackoff time.Duration
+	var retryJitter float64
+	var includes, excludes patternList
+	var bufferSize, walkers, validators, copiers int
+	var maxMemory int64
 	flag.StringVar(&src, "s", "", "source folder")
 	flag.StringVar(&dest, "d", "", "destination folder")
 	flag.BoolVar(&isVerbose, "v", false, "verbose")
 	flag.BoolVar(&createEmptyFolder, "e", false, "create empty folder")
+	flag.BoolVar(&isDelete, "delete", false, "delete files in destination which do not exist in source")
+	flag.BoolVar(&isDryRun, "n", false, "dry run, print the changes without writing anything")
+	flag.BoolVar(&showProgress, "progress", false, "show a live progress line, periodic log lines when stdout is not a terminal (stderr when the report goes to stdout)")
+	flag.BoolVar(&isFsync, "fsync", false, "flush every copied file to disk before replacing the destination")
+	flag.BoolVar(&preservePerms, "p", false, "preserve permissions")
+	flag.BoolVar(&preserveTimes, "t", false, "preserve modification and access times")
+	flag.BoolVar(&isArchive, "a", false, "archive mode, same as -p -t")
+	flag.StringVar(&compare, "compare", "default", "change detection: default (size, newer source, content), quick (size and mtime, use with -t or every file is copied again), checksum (size, content), size or always")
+	flag.StringVar(&checksumAlgo, "checksum-algo", "md5", "checksum algorithm: md5, sha256, sha512, blake2b or crc32c")
+	flag.Var(&includes, "include", "only sync files matching the glob pattern, ** matches any directories (repeatable)")
+	flag.Var(&excludes, "exclude", "skip files and folders matching the glob pattern, ** matches any directories (repeatable)")
+	flag.BoolVar(&useGitignore, "gitignore", false, "honor .gitignore files in addition to .syncignore files")
+	flag.IntVar(&walkers, "walkers", 0, "number of walker workers (default number of CPUs)")
+	flag.IntVar(&validators, "validators", 0, "number of validator workers (default twice the number of CPUs)")
+	flag.IntVar(&copiers, "copiers", 0, "number of copier workers (default number of CPUs)")
+	flag.IntVar(&bufferSize, "buffer-size", dsync.DefaultBufferSize, "size in bytes of each copy buffer")
+	flag.Int64Var(&maxMemory, "max-memory", dsync.DefaultMaxMemory, "maximum bytes of copy buffers in flight")
+	flag.StringVar(&logFormat, "log-format", "text", "log format: text or json, logs are written to stderr")
+	flag.StringVar(&logLevel, "log-level", "", "log level: debug, info, warn or error (default none, debug with -v)")
+	flag.StringVar(&reportFormat, "report", "", "write a run report at the end of the run, only json is supported")
+	flag.StringVar(&reportFile, "report-file", "", "file receiving the run report, implies -report json (default stdout)")
+	flag.StringVar(&onError, "on-error", "fail-fast", "error policy: fail-fast stops on the first failed file, continue syncs every other file")
+	flag.IntVar(&maxErrors, "max-errors", 0, "stop once this many files failed, overrides -on-error when positive")
+	flag.IntVar(&retries, "retries", 0, "retries of a file read, write or stat failing with a transient error (EIO, EAGAIN, ETIMEDOUT, ESTALE)")
+	flag.DurationVar(&retryBackoff, "retry-backoff", dsync.DefaultRetryBackoff, "wait before the first retry, doubled after each one")
+	flag.DurationVar(&retryMaxBackoff, "retry-max-backoff", dsync.DefaultRetryMaxBackoff, "maximum wait between retries")
+	flag.Float64Var(&retryJitter, "retry-jitter", dsync.DefaultRetryJitter, "random fraction added to or removed from each wait")
+	flag.StringVar(&symlinks, "symlinks", "follow", "symlink handling: follow, copy (as links), skip or safe (follow only links staying inside source)")
+	flag.BoolVar(&hardLinks, "hard-links", false, "recreate files sharing an inode in source as hard links in destination")
+	flag.BoolVar(&isSparse, "sparse", false, "keep the holes of sparse files and turn blocks of zeros into holes")
+	flag.BoolVar(&resume, "resume", false, "keep the partial file of an interrupted copy and append to it on the next run")
+	flag.BoolVar(&useDelta, "delta", false, "rebuild updated files from the unchanged blocks of the destination file (rsync delta algorithm)")
 	flag.Parse()
 
 	if dest == "" || src == "" {
 		fmt.Println("Usage: sync [-ds], where:")
 		flag.PrintDefaults()
-		os.Exit(1)
+		os.Exit(exitInvalidUsage)
+	}
+
+	if reportFile != "" && reportFormat == "" {
+		reportFormat = "json"
+	}
+	if reportFormat != "" && reportFormat != "json" {
+		checkErr(dsyncerr.Wrap(dsyncerr.ErrInvalidUsage, fmt.Errorf("unknown report format %q, must be json", reportFormat)))
 	}
+	// a report on stdout replaces the human readable output
+	reportOnStdout := reportFormat != "" && (reportFile == "" || reportFile == "-")
+
+	if isArchive {
+		preservePerms, preserveTimes = true, true
+	}
+
+	comparator, err := dsync.ComparatorByName(compare)
+	checkErr(err)
+
+	hasher, err := dsync.HasherByName(checksumAlgo)
+	checkErr(err)
+
+	symlinkMode, err := dsync.SymlinkModeByName(symlinks)
+	checkErr(err)
+
+	errorPolicy, err := dsync.ErrorPolicyByName(onError)
+	checkErr(err)
+	if maxErrors > 0 {
+		errorPolicy = dsync.AbortAfter(maxErrors)
+	}
+
+	logger, err := newLogger(os.Stderr, logFormat, logLevel, isVerbose)
+	checkErr(dsyncerr.Wrap(dsyncerr.ErrInvalidUsage, err))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	_, err := isDir(src)
-	checkErr(err)
+	_, err = isDir(src)
+	if os.IsPermission(err) {
+		err = dsyncerr.Wrap(dsyncerr.ErrSourceUnreadable, err)
+	}
+	checkErr(usageErr(err))
 
 	_, err = isDir(dest)
-	checkErr(err)
+	checkErr(usageErr(err))
+
+	// events are only emitted when someone renders them
+	var events chan dsync.Event
+	barDone := make(chan struct{})
+	if showProgress {
+		events = make(chan dsync.Event, 1024)
+		// stdout only carries the report when it goes there, so it stays parseable
+		progressOut := os.Stdout
+		if reportOnStdout {
+			progressOut = os.Stderr
+		}
+		bar := dsyncprogress.New(progressOut, dsyncprogress.IsTerminal(progressOut))
+		go func() {
+			bar.Run(events)
+			close(barDone)
+		}()
+	} else {
+		close(barDone)
+	}
 
-	ds, err := dsync.New(ctx, src, dest, dsync.WithVerbose(isVerbose), dsync.WithCreateEmptyFolder(createEmptyFolder))
+	ds, err := dsync.New(ctx, src, dest,
+		dsync.WithVerbose(isVerbose),
+		dsync.WithLogger(logger),
+		dsync.WithCreateEmptyFolder(createEmptyFolder),
+		dsync.WithDelete(isDelete),
+		dsync.WithDryRun(isDryRun),
+		dsync.WithBufferSize(bufferSize),
+		dsync.WithMaxMemory(maxMemory),
+		dsync.WithFsync(isFsync),
+		dsync.WithPreservePerms(preservePerms),
+		dsync.WithPreserveTimes(preserveTimes),
+		dsync.WithComparator(comparator),
+		dsync.WithHasher(hasher),
+		dsync.WithInclude(includes...),
+		dsync.WithExclude(excludes...),
+		dsync.WithGitignore(useGitignore),
+		dsync.WithWalkers(walkers),
+		dsync.WithValidators(validators),
+		dsync.WithCopiers(copiers),
+		dsync.WithErrorPolicy(errorPolicy),
+		dsync.WithSymlinks(symlinkMode),
+		dsync.WithHardLinks(hardLinks),
+		dsync.WithSparse(isSparse),
+		dsync.WithDelta(useDelta),
+		dsync.WithResume(resume),
+		dsync.WithRetry(dsync.RetryPolicy{
+			Attempts:   retries + 1,
+			Backoff:    retryBackoff,
+			MaxBackoff: retryMaxBackoff,
+			Jitter:     retryJitter,
+		}),
+		dsync.WithProgress(events))
 	checkErr(err)
 
 	// Setting up a channel to capture system signals
@@ -81,7 +305,52 @@ func main() {
 	}()
 
 	err = ds.DoSync(ctx)
-	checkErr(err)
+	if events != nil {
+		close(events)
+	}
+	<-barDone
+	if reportFormat != "" {
+		checkErr(writeReport(ds.GetReport(), reportFile))
+	}
+	if reportOnStdout {
+		// stdout only holds the report, the error is already part of it
+		exitOnErr(os.Stderr, err)
+		return
+	}
+	// a partial transfer still prints what was done before exiting with its code
+	if !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+		checkErr(err)
+	}
+
+	if isDryRun {
+		checkErr(ds.GetPlan().Print(os.Stdout))
+		checkErr(err)
+		return
+	}
 
+	summary := ds.GetSummary()
 	fmt.Println("Total files processed:", ds.GetTotal())
+	fmt.Println("New files:", summary.New)
+	fmt.Println("Updated files:", summary.Updated)
+	fmt.Println("Unchanged files:", summary.Unchanged)
+	if isDelete {
+		fmt.Println("Deleted files:", summary.Deleted)
+	}
+	if summary.Symlinks > 0 {
+		fmt.Println("Symlinks:", summary.Symlinks)
+	}
+	if summary.HardLinks > 0 {
+		fmt.Println("Hard links:", summary.HardLinks)
+	}
+	if isSparse {
+		fmt.Println("Apparent size:", dsyncprogress.HumanBytes(summary.Bytes))
+		fmt.Println("Allocated size:", dsyncprogress.HumanBytes(summary.Allocated))
+	}
+	if useDelta {
+		fmt.Println("Reused from destination:", dsyncprogress.HumanBytes(summary.Matched))
+	}
+	if summary.Failed > 0 {
+		fmt.Println("Failed files:", summary.Failed)
+	}
+	checkErr(err)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain will run main with the arguments of SYNC_ARGS when the test binary is started
+// by runCLI, so the command line can be checked end to end
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("SYNC_ARGS"); ok {
+		os.Args = append([]string{"sync"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(exitOK)
+	}
+	os.Exit(m.Run())
+}
+
+// runCLI will run the command line and return its stdout, stderr and exit code
+func runCLI(t *testing.T, args ...string) ([]byte, []byte, int) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "SYNC_ARGS="+strings.Join(args, "\n"))
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.Bytes(), stderr.Bytes(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("error %v", err)
+	}
+	return stdout.Bytes(), stderr.Bytes(), exitOK
+}
+
+func TestReportOnStdout(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "file"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("error %v", err)
+	}
+
+	t.Run("success only the report on stdout", func(t *testing.T) {
+		stdout, _, code := runCLI(t, "-progress", "-report", "json", "-s", srcDir, "-d", dstDir)
+		var report map[string]interface{}
+		if err := json.Unmarshal(stdout, &report); err != nil {
+			t.Errorf("stdout must be the report, got %v: %s", err, stdout)
+		}
+		if code != exitOK || report["copied"] != float64(1) {
+			t.Errorf("must copy 1 file with exit code 0, got %d %v", code, report["copied"])
+		}
+	})
+
+	t.Run("fail error written to stderr", func(t *testing.T) {
+		if err := os.Symlink(filepath.Join(srcDir, "missing"), filepath.Join(srcDir, "dangle")); err != nil {
+			t.Fatalf("error %v", err)
+		}
+		stdout, stderr, code := runCLI(t, "-report", "json", "-s", srcDir, "-d", dstDir)
+		var report map[string]interface{}
+		if err := json.Unmarshal(stdout, &report); err != nil {
+			t.Errorf("stdout must be the report, got %v: %s", err, stdout)
+		}
+		if code != exitPartialTransfer || report["failed"] != float64(1) {
+			t.Errorf("must fail 1 file with exit code %d, got %d %v", exitPartialTransfer, code, report["failed"])
+		}
+		if !strings.Contains(string(stderr), "dangling symlink") {
+			t.Errorf("error must be written to stderr, got %s", stderr)
+		}
+	})
+}
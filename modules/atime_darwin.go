@@ -0,0 +1,16 @@
+package dsync
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// accessTime will return the last access time of a file
+func accessTime(info fs.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package dsync
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime will return the modification time as the access time is not portable
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
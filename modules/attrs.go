@@ -0,0 +1,94 @@
+package dsync
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// attrsDiffer will check if the preserved attributes of the destination differ from
+// the operation, the access time is ignored as reading a file changes it
+func (ds *DirSync) attrsDiffer(op Operation, dstInfo fs.FileInfo) bool {
+	if ds.PreservePerms && op.Mode.Perm() != dstInfo.Mode().Perm() {
+		return true
+	}
+	if ds.PreserveTimes && !op.ModTime.Equal(dstInfo.ModTime()) {
+		return true
+	}
+	return false
+}
+
+// setAttrs will carry over the preserved mode and times of the operation to path
+func (ds *DirSync) setAttrs(path string, op Operation) error {
+	if ds.PreservePerms {
+		if err := os.Chmod(path, op.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	if ds.PreserveTimes {
+		if err := os.Chtimes(path, op.AccessTime, op.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deferDir will keep a directory operation until its content is written, writing
+// into a directory changes its modification time and may need write permission
+func (ds *DirSync) deferDir(op Operation) {
+	if !ds.PreservePerms && !ds.PreserveTimes {
+		return
+	}
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	ds.pendingDirs = append(ds.pendingDirs, op)
+}
+
+// deferParent will keep the attributes of the source directory of an entry which was
+// written or deleted, its destination directory got the current time meanwhile
+func (ds *DirSync) deferParent(op Operation) {
+	if !ds.PreservePerms && !ds.PreserveTimes {
+		return
+	}
+	dir := filepath.Dir(op.Dst)
+	if dir == ds.AbsDstRoot || !isInside(dir, ds.AbsDstRoot) {
+		return // the roots are not synced
+	}
+	srcDir := ds.AbsSrcRoot + strings.TrimPrefix(dir, ds.AbsDstRoot)
+	info, err := os.Stat(srcDir)
+	if err != nil {
+		ds.Logger.Debug("fail stat source directory, attributes kept", "path", srcDir, "error", err)
+		return
+	}
+	ds.deferDir(Operation{Kind: OpAttrs, Dst: dir, Mode: info.Mode(), ModTime: info.ModTime(), AccessTime: accessTime(info)})
+}
+
+// finalizeDirs will set the attributes of the deferred directories, deepest first, every
+// failure is recorded and the error policy decides if the remaining directories are done
+func (ds *DirSync) finalizeDirs(ctx context.Context) error {
+	ds.lock.Lock()
+	dirs := ds.pendingDirs
+	ds.pendingDirs = nil
+	ds.lock.Unlock()
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i].Dst, string(os.PathSeparator)) > strings.Count(dirs[j].Dst, string(os.PathSeparator))
+	})
+	done := make(map[string]bool, len(dirs))
+	for _, op := range dirs {
+		if done[op.Dst] {
+			continue // deferred once per entry written into it
+		}
+		done[op.Dst] = true
+		if err := ds.setAttrs(op.Dst, op); err != nil {
+			ds.Logger.Error("fail set attributes", "path", op.Dst, "error", err)
+			if errStop := ds.handleFailure(ctx, op, err); errStop != nil {
+				return errStop
+			}
+		}
+	}
+	return nil
+}
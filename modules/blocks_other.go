@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package dsync
+
+import (
+	"io/fs"
+)
+
+// allocatedSize will return the size of a file as its allocation is not portable
+func allocatedSize(info fs.FileInfo) int64 {
+	return info.Size()
+}
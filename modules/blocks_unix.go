@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+
+package dsync
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// allocatedSize will return the disk space used by a file, smaller than its size when it has holes
+func allocatedSize(info fs.FileInfo) int64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return st.Blocks * 512
+}
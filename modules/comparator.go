@@ -0,0 +1,98 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"time"
+)
+
+// FileState is what a Comparator knows about a source or destination file
+type FileState struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Checksummer will compute the checksum of a file content
+type Checksummer interface {
+	Checksum(ctx context.Context, path string) ([]byte, error)
+}
+
+// Comparator decides if an existing destination file must be refreshed from its source
+type Comparator interface {
+	Changed(ctx context.Context, src, dst FileState, sum Checksummer) (bool, error)
+}
+
+// QuickComparator reports a change when the size or the modification time (in seconds) differs,
+// the destination times must be preserved (WithPreserveTimes) otherwise every file is copied again
+type QuickComparator struct{}
+
+func (QuickComparator) Changed(_ context.Context, src, dst FileState, _ Checksummer) (bool, error) {
+	if src.Size != dst.Size {
+		return true, nil
+	}
+	return src.ModTime.Unix() != dst.ModTime.Unix(), nil
+}
+
+// DefaultComparator reports a change when the size differs, the source is newer than the
+// destination or the checksum of the content differs
+type DefaultComparator struct{}
+
+func (DefaultComparator) Changed(ctx context.Context, src, dst FileState, sum Checksummer) (bool, error) {
+	if src.ModTime.After(dst.ModTime) {
+		return true, nil
+	}
+	return ChecksumComparator{}.Changed(ctx, src, dst, sum)
+}
+
+// ChecksumComparator reports a change when the size or the checksum of the content differs
+type ChecksumComparator struct{}
+
+func (ChecksumComparator) Changed(ctx context.Context, src, dst FileState, sum Checksummer) (bool, error) {
+	if src.Size != dst.Size {
+		return true, nil
+	}
+
+	// files are hashed one after another so a validator holds a single buffer
+	sumSrc, err := sum.Checksum(ctx, src.Path)
+	if err != nil {
+		return false, err
+	}
+	sumDst, err := sum.Checksum(ctx, dst.Path)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(sumSrc, sumDst), nil
+}
+
+// SizeComparator reports a change only when the size differs
+type SizeComparator struct{}
+
+func (SizeComparator) Changed(_ context.Context, src, dst FileState, _ Checksummer) (bool, error) {
+	return src.Size != dst.Size, nil
+}
+
+// AlwaysComparator always reports a change so every file is copied
+type AlwaysComparator struct{}
+
+func (AlwaysComparator) Changed(context.Context, FileState, FileState, Checksummer) (bool, error) {
+	return true, nil
+}
+
+// ComparatorByName will return the built-in comparator: default, quick, checksum, size or always
+func ComparatorByName(name string) (Comparator, error) {
+	switch name {
+	case "default":
+		return DefaultComparator{}, nil
+	case "quick":
+		return QuickComparator{}, nil
+	case "checksum":
+		return ChecksumComparator{}, nil
+	case "size":
+		return SizeComparator{}, nil
+	case "always":
+		return AlwaysComparator{}, nil
+	}
+	return nil, dsyncerr.ErrUnknownComparator
+}
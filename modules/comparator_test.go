@@ -0,0 +1,143 @@
+package dsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type fakeChecksummer map[string]string
+
+func (f fakeChecksummer) Checksum(_ context.Context, path string) ([]byte, error) {
+	sum, ok := f[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(sum), nil
+}
+
+func TestComparators(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	sums := fakeChecksummer{"src": "aaa", "same": "aaa", "other": "bbb"}
+
+	tests := []struct {
+		name       string
+		comparator string
+		src        FileState
+		dst        FileState
+		want       bool
+	}{
+		{"quick same size and mtime", "quick", FileState{"src", 5, now}, FileState{"other", 5, now}, false},
+		{"quick different mtime", "quick", FileState{"src", 5, now}, FileState{"same", 5, now.Add(time.Hour)}, true},
+		{"quick different size", "quick", FileState{"src", 5, now}, FileState{"same", 6, now}, true},
+		{"checksum same content", "checksum", FileState{"src", 5, now}, FileState{"same", 5, now.Add(time.Hour)}, false},
+		{"checksum different content", "checksum", FileState{"src", 5, now}, FileState{"other", 5, now}, true},
+		{"checksum newer source same content", "checksum", FileState{"src", 5, now.Add(time.Hour)}, FileState{"same", 5, now}, false},
+		{"default newer source same content", "default", FileState{"src", 5, now.Add(time.Hour)}, FileState{"same", 5, now}, true},
+		{"default older source same content", "default", FileState{"src", 5, now}, FileState{"same", 5, now.Add(time.Hour)}, false},
+		{"default different content", "default", FileState{"src", 5, now}, FileState{"other", 5, now}, true},
+		{"default different size", "default", FileState{"src", 5, now}, FileState{"same", 6, now}, true},
+		{"size same size", "size", FileState{"src", 5, now}, FileState{"other", 5, now.Add(time.Hour)}, false},
+		{"size different size", "size", FileState{"src", 5, now}, FileState{"same", 6, now}, true},
+		{"always", "always", FileState{"src", 5, now}, FileState{"same", 5, now}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparator, err := ComparatorByName(tt.comparator)
+			if err != nil {
+				t.Errorf("must be nil")
+			}
+			got, err := comparator.Changed(ctx, tt.src, tt.dst, sums)
+			if err != nil {
+				t.Errorf("must be nil")
+			}
+			if got != tt.want {
+				t.Errorf("changed must be %t", tt.want)
+			}
+		})
+	}
+
+	t.Run("fail unknown comparator", func(t *testing.T) {
+		_, err := ComparatorByName("md4")
+		if !errors.Is(err, dsyncerr.ErrUnknownComparator) {
+			t.Errorf("err must be %s", dsyncerr.ErrUnknownComparator)
+		}
+	})
+}
+
+func TestDosyncComparator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success size comparator skips same size file", func(t *testing.T) {
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		writeFile(targetSrc, "hello")
+		targetDst := fmt.Sprintf("%s/%s", destinationDir, targetSrc[len(sourceDir)+1:])
+		writeFile(targetDst, "hella")
+
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(targetSrc, targetDst)
+
+		ds, err := New(ctx, sourceDir, destinationDir, WithComparator(SizeComparator{}))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+		data, _ := os.ReadFile(targetDst)
+		if string(data) != "hella" {
+			t.Errorf("destination must not be updated")
+		}
+	})
+
+	t.Run("success default comparator updates newer source", func(t *testing.T) {
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		writeFile(targetSrc, "hello")
+		targetDst := fmt.Sprintf("%s/%s", destinationDir, targetSrc[len(sourceDir)+1:])
+		writeFile(targetDst, "hello")
+
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(targetSrc, targetDst)
+
+		newer := time.Now().Add(time.Hour)
+		if err := os.Chtimes(targetSrc, newer, newer); err != nil {
+			t.Errorf("error %v", err)
+		}
+
+		ds, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+		if summary := ds.GetSummary(); summary.Updated != 1 {
+			t.Errorf("newer source must be updated, got %+v", summary)
+		}
+
+		// the copy is newer than its source so the next run leaves it alone
+		if err = os.Chtimes(targetSrc, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+			t.Errorf("error %v", err)
+		}
+		ds, err = New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+		if summary := ds.GetSummary(); summary.Updated != 0 {
+			t.Errorf("must be unchanged, got %+v", summary)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	dsyncdelta "github.com/bondhan/sync/modules/delta"
+	"hash"
+	"io"
+	"os"
+)
+
+// blockHash will return the hash of the strong block checksums, the one used to compare
+// files unless it is shorter than 128 bits like crc32c, then sha256
+func (ds *DirSync) blockHash() func() hash.Hash {
+	if ds.Hasher.New().Size() < dsyncdelta.MinStrongSize {
+		return SHA256Hasher.New
+	}
+	return ds.Hasher.New
+}
+
+// copyDelta will rebuild the new content of op.Dst into tmp from the blocks of the current
+// op.Dst found in src and the literal data between them, like rsync does over the wire
+// it returns false without writing anything when there is no destination content to reuse,
+// or when the rebuilt file differs from the source so it is copied whole
+func (ds *DirSync) copyDelta(ctx context.Context, tmp *os.File, src io.Reader, op Operation) (bool, error) {
+	seeker, ok := src.(io.Seeker)
+	if !ok {
+		return false, nil // the source could not be read again after a failed delta
+	}
+	base, err := os.Open(op.Dst)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer func(f *os.File) {
+		if errClose := f.Close(); errClose != nil {
+			ds.Logger.Warn("fail close file", "path", op.Dst, "error", errClose)
+		}
+	}(base)
+
+	info, err := base.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() == 0 {
+		return false, nil
+	}
+
+	// the signature and the buffers of the delta are counted against the memory limit
+	blockSize := dsyncdelta.BlockSizeFor(info.Size())
+	strong := ds.blockHash()
+	release, err := ds.buffers.reserve(ctx, dsyncdelta.MemorySize(info.Size(), blockSize, strong().Size()))
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	sig, err := dsyncdelta.NewSignature(&ctxReader{ctx, base}, blockSize, strong)
+	if err != nil {
+		return false, err
+	}
+
+	var w io.Writer = tmp
+	if ds.Sparse {
+		w = &sparseWriter{f: tmp}
+	}
+	// like rsync the whole file is checked, a false block match would corrupt it silently,
+	// sha256 keeps the check apart from the block checksums
+	srcSum, dstSum := SHA256Hasher.New(), SHA256Hasher.New()
+	patcher := dsyncdelta.NewPatcher(io.MultiWriter(&progressWriter{ctx: ctx, ds: ds, path: op.Src, w: w}, dstSum), base, sig.BlockSize)
+	var matched, literal int64
+	err = sig.Delta(io.TeeReader(&ctxReader{ctx, src}, srcSum), strong, func(o dsyncdelta.Op) error {
+		if o.Kind == dsyncdelta.OpBlock {
+			matched += int64(sig.BlockSize)
+			if last := int64(o.Index+1) * int64(sig.BlockSize); last > sig.FileSize {
+				matched -= last - sig.FileSize
+			}
+		} else {
+			literal += int64(len(o.Data))
+		}
+		return patcher.Apply(o)
+	})
+	if err != nil {
+		return true, err
+	}
+	if !bytes.Equal(srcSum.Sum(nil), dstSum.Sum(nil)) {
+		ds.Logger.Warn("delta differs from source, copied whole", "path", op.Dst)
+		if err = tmp.Truncate(0); err != nil {
+			return true, err
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return true, err
+		}
+		_, err = seeker.Seek(0, io.SeekStart)
+		return false, err
+	}
+	if ds.Sparse {
+		// a trailing hole is only made by the file size
+		if err = tmp.Truncate(matched + literal); err != nil {
+			return true, err
+		}
+	}
+
+	ds.Logger.Debug("delta transfer", "path", op.Dst, "matched", matched, "literal", literal)
+	ds.lock.Lock()
+	ds.TotalMatched += matched
+	ds.lock.Unlock()
+	return true, nil
+}
@@ -0,0 +1,296 @@
+package dsyncdelta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"math"
+)
+
+const (
+	MinBlockSize = 1024
+	MaxBlockSize = 128 * 1024
+	// maxLiteral is the largest literal sent in a single operation
+	maxLiteral = 64 * 1024
+	// readSize is the buffer of the reader scanning the source
+	readSize = 64 * 1024
+	// MinStrongSize is the smallest strong checksum accepted, 128 bits
+	MinStrongSize = 16
+	// blockOverhead is about what a block costs in a signature and its index besides its strong checksum
+	blockOverhead = 96
+)
+
+var (
+	ErrInvalidBlockSize = errors.New("block size must be positive")
+	ErrInvalidOp        = errors.New("unknown delta operation")
+	ErrNoStrongHash     = errors.New("strong hash of at least 128 bits is required")
+)
+
+// BlockSizeFor will return a block size for a file of the given size, the square root
+// of the size like rsync, bounded by MinBlockSize and MaxBlockSize
+func BlockSizeFor(size int64) int {
+	bs := int(math.Sqrt(float64(size)))
+	if bs < MinBlockSize {
+		return MinBlockSize
+	}
+	if bs > MaxBlockSize {
+		return MaxBlockSize
+	}
+	return bs
+}
+
+// MemorySize will estimate the memory used to compute and apply the delta of a file of the
+// given size, the signature of its blocks plus the buffers of Delta and of the Patcher
+func MemorySize(fileSize int64, blockSize, strongSize int) int64 {
+	if blockSize <= 0 {
+		return 0
+	}
+	blocks := (fileSize + int64(blockSize) - 1) / int64(blockSize)
+	return blocks*int64(blockOverhead+strongSize) + int64(readSize+maxLiteral+6*blockSize)
+}
+
+// BlockSig holds the checksums of a block of the base file
+type BlockSig struct {
+	Weak   uint32 `json:"weak"`
+	Strong []byte `json:"strong"`
+}
+
+// Signature describes the base file the delta is computed against, it is all the
+// sending side needs to know about it
+type Signature struct {
+	BlockSize int        `json:"block_size"`
+	FileSize  int64      `json:"file_size"`
+	Blocks    []BlockSig `json:"blocks"`
+
+	index map[uint32][]int
+}
+
+// NewSignature will read the base file and compute the weak and strong checksums of its blocks,
+// the strong checksums are computed by a hash of strong, of at least MinStrongSize bytes, and
+// Delta must be given the same one
+func NewSignature(r io.Reader, blockSize int, strong func() hash.Hash) (*Signature, error) {
+	if blockSize <= 0 {
+		return nil, ErrInvalidBlockSize
+	}
+	if strong == nil || strong().Size() < MinStrongSize {
+		return nil, ErrNoStrongHash
+	}
+
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	h := strong()
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			a, b := checksum(buf[:n])
+			h.Reset()
+			h.Write(buf[:n]) //nolint:errcheck // a hash never fails to write
+			sig.Blocks = append(sig.Blocks, BlockSig{Weak: weak(a, b), Strong: h.Sum(nil)})
+			sig.FileSize += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// blockLen will return the length of the block at index, only the last one may be short
+func (s *Signature) blockLen(index int) int {
+	rest := s.FileSize - int64(index)*int64(s.BlockSize)
+	if rest < int64(s.BlockSize) {
+		return int(rest)
+	}
+	return s.BlockSize
+}
+
+// match will return the index of a block with the content of win, h computes its strong
+// checksum into sum only when the weak checksum matches
+func (s *Signature) match(w uint32, win []byte, h hash.Hash, sum []byte) (int, bool) {
+	candidates, ok := s.index[w]
+	if !ok {
+		return 0, false
+	}
+	computed := false
+	for _, i := range candidates {
+		if s.blockLen(i) != len(win) {
+			continue
+		}
+		if !computed {
+			h.Reset()
+			h.Write(win) //nolint:errcheck // a hash never fails to write
+			sum, computed = h.Sum(sum[:0]), true
+		}
+		if bytes.Equal(sum, s.Blocks[i].Strong) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// OpKind is the kind of a delta operation
+type OpKind string
+
+const (
+	OpBlock   OpKind = "block"   // copy the block Index of the base file
+	OpLiteral OpKind = "literal" // write Data
+)
+
+// Op is a single step to rebuild the source from the base file
+type Op struct {
+	Kind  OpKind `json:"kind"`
+	Index int    `json:"index,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+// Delta will read the source and call fn with the operations rebuilding it from the base
+// file of the signature, Data is only valid during the call and must be copied to be kept
+func (s *Signature) Delta(r io.Reader, strong func() hash.Hash, fn func(Op) error) error {
+	if s.BlockSize <= 0 {
+		return ErrInvalidBlockSize
+	}
+	if strong == nil || strong().Size() < MinStrongSize {
+		return ErrNoStrongHash
+	}
+	h := strong()
+	sum := make([]byte, 0, h.Size())
+	if s.index == nil {
+		s.index = make(map[uint32][]int, len(s.Blocks))
+		for i, b := range s.Blocks {
+			s.index[b.Weak] = append(s.index[b.Weak], i)
+		}
+	}
+
+	br := bufio.NewReaderSize(r, readSize)
+	bs := s.BlockSize
+	// the window is buf[start:], bytes before start are already sent as literals
+	buf := make([]byte, 0, 4*bs)
+	start := 0
+	eof := false
+	literal := make([]byte, 0, maxLiteral)
+
+	flush := func() error {
+		if len(literal) == 0 {
+			return nil
+		}
+		err := fn(Op{Kind: OpLiteral, Data: literal})
+		literal = literal[:0]
+		return err
+	}
+	fill := func() error {
+		n, err := io.ReadFull(br, buf[:bs])
+		buf, start = buf[:n], 0
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			eof = true
+			return nil
+		}
+		return err
+	}
+
+	if err := fill(); err != nil {
+		return err
+	}
+	a, b := checksum(buf)
+	for start < len(buf) {
+		win := buf[start:]
+		if i, ok := s.match(weak(a, b), win, h, sum); ok {
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := fn(Op{Kind: OpBlock, Index: i}); err != nil {
+				return err
+			}
+			if eof {
+				break
+			}
+			if err := fill(); err != nil {
+				return err
+			}
+			a, b = checksum(buf)
+			continue
+		}
+
+		// roll the window one byte forward, the byte leaving it becomes literal
+		out := uint32(buf[start])
+		literal = append(literal, buf[start])
+		if len(literal) == maxLiteral {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		a -= out
+		b -= uint32(len(win)) * out
+		start++
+		if eof {
+			continue
+		}
+
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			eof = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(buf) == cap(buf) {
+			buf = buf[:copy(buf, buf[start:])]
+			start = 0
+		}
+		buf = append(buf, c)
+		a += uint32(c)
+		b += a
+	}
+	return flush()
+}
+
+// checksum will compute the two halves of the rolling checksum of rsync, the plain sum of
+// the bytes and the sum weighted by their distance to the end of the block
+func checksum(p []byte) (uint32, uint32) {
+	var a, b uint32
+	l := uint32(len(p))
+	for i, c := range p {
+		a += uint32(c)
+		b += (l - uint32(i)) * uint32(c)
+	}
+	return a, b
+}
+
+// weak will combine the two halves of the rolling checksum
+func weak(a, b uint32) uint32 {
+	return a&0xffff | b<<16
+}
+
+// Patcher rebuilds the source by writing the operations of a delta against the base file
+type Patcher struct {
+	w         io.Writer
+	base      io.ReaderAt
+	blockSize int
+	buf       []byte
+}
+
+// NewPatcher will create a patcher writing to w the blocks of base and the literals
+func NewPatcher(w io.Writer, base io.ReaderAt, blockSize int) *Patcher {
+	return &Patcher{w: w, base: base, blockSize: blockSize, buf: make([]byte, blockSize)}
+}
+
+// Apply will write the content of a single operation
+func (p *Patcher) Apply(op Op) error {
+	switch op.Kind {
+	case OpLiteral:
+		_, err := p.w.Write(op.Data)
+		return err
+	case OpBlock:
+		n, err := p.base.ReadAt(p.buf, int64(op.Index)*int64(p.blockSize))
+		if err != nil && !(err == io.EOF && n > 0) {
+			return err
+		}
+		_, err = p.w.Write(p.buf[:n])
+		return err
+	}
+	return ErrInvalidOp
+}
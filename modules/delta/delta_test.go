@@ -0,0 +1,121 @@
+package dsyncdelta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+// roundTrip will rebuild src from base and return the number of literal bytes sent
+func roundTrip(t *testing.T, base, src []byte, blockSize int) int {
+	sig, err := NewSignature(bytes.NewReader(base), blockSize, sha256.New)
+	if err != nil {
+		t.Fatalf("must be nil, got %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	p := NewPatcher(out, bytes.NewReader(base), blockSize)
+	literal := 0
+	err = sig.Delta(bytes.NewReader(src), sha256.New, func(op Op) error {
+		literal += len(op.Data)
+		return p.Apply(op)
+	})
+	if err != nil {
+		t.Fatalf("must be nil, got %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), src) {
+		t.Fatalf("rebuilt file differs from source, %d bytes instead of %d", out.Len(), len(src))
+	}
+	return literal
+}
+
+func TestDelta(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	base := make([]byte, 64*1024+100)
+	rnd.Read(base)
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name       string
+		base, src  []byte
+		maxLiteral int
+	}{
+		{"unchanged", base, base, 0},
+		{"byte changed", join(base[:5000], []byte{^base[5000]}, base[5001:]), base, 1024},
+		{"inserted", base, join(base[:3000], []byte("inserted"), base[3000:]), 1024 + 8},
+		{"deleted", base, join(base[:3000], base[3100:]), 2 * 1024},
+		{"appended", base, join(base, []byte("tail of a log file")), 100 + 18},
+		{"truncated", base, base[:10000], 10000 % 1024},
+		{"empty base", nil, base, len(base)},
+		{"empty source", base, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if literal := roundTrip(t, tt.base, tt.src, 1024); literal > tt.maxLiteral {
+				t.Errorf("must send at most %d literal bytes, sent %d", tt.maxLiteral, literal)
+			}
+		})
+	}
+
+	t.Run("success with a window larger than the buffer", func(t *testing.T) {
+		src := make([]byte, 200*1024)
+		rnd.Read(src)
+		roundTrip(t, base, src, 1024)
+	})
+
+	t.Run("fail test invalid block size", func(t *testing.T) {
+		if _, err := NewSignature(bytes.NewReader(base), 0, sha256.New); err != ErrInvalidBlockSize {
+			t.Errorf("must be ErrInvalidBlockSize, got %v", err)
+		}
+		if _, err := NewSignature(bytes.NewReader(base), 1024, nil); err != ErrNoStrongHash {
+			t.Errorf("must be ErrNoStrongHash, got %v", err)
+		}
+		if _, err := NewSignature(bytes.NewReader(base), 1024, func() hash.Hash { return crc32.NewIEEE() }); err != ErrNoStrongHash {
+			t.Errorf("must be ErrNoStrongHash, got %v", err)
+		}
+		if err := NewPatcher(&bytes.Buffer{}, bytes.NewReader(base), 1024).Apply(Op{Kind: "move"}); err != ErrInvalidOp {
+			t.Errorf("must be ErrInvalidOp, got %v", err)
+		}
+	})
+}
+
+func TestRollingChecksum(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	data := make([]byte, 4096)
+	rnd.Read(data)
+
+	const l = 512
+	a, b := checksum(data[:l])
+	for i := 1; i+l <= len(data); i++ {
+		out, in := uint32(data[i-1]), uint32(data[i+l-1])
+		a -= out
+		b -= l * out
+		a += in
+		b += a
+		wantA, wantB := checksum(data[i : i+l])
+		if weak(a, b) != weak(wantA, wantB) {
+			t.Fatalf("rolled checksum differs at offset %d", i)
+		}
+	}
+}
+
+func TestMemorySize(t *testing.T) {
+	small, large := MemorySize(1<<20, 1024, 32), MemorySize(1<<30, 1024, 32)
+	if small <= 6*1024 || large <= small {
+		t.Errorf("must grow with the number of blocks, got %d and %d", small, large)
+	}
+	if MemorySize(1<<20, 0, 32) != 0 {
+		t.Errorf("must be 0 for an invalid block size")
+	}
+}
+
+func TestBlockSizeFor(t *testing.T) {
+	for size, want := range map[int64]int{0: MinBlockSize, 1 << 24: 4096, 1 << 40: MaxBlockSize} {
+		if got := BlockSizeFor(size); got != want {
+			t.Errorf("must be %d for %d, got %d", want, size, got)
+		}
+	}
+}
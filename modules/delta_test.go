@@ -0,0 +1,122 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// collidingHash sums every content to the same 128 bits, every weak match is then taken
+type collidingHash struct{ hash.Hash }
+
+func (collidingHash) Sum(b []byte) []byte { return append(b, make([]byte, 16)...) }
+func (collidingHash) Size() int           { return 16 }
+
+type collidingHasher struct{}
+
+func (collidingHasher) Name() string   { return "colliding" }
+func (collidingHasher) New() hash.Hash { return collidingHash{crc32.NewIEEE()} }
+
+func TestDosyncDelta(t *testing.T) {
+	ctx := context.Background()
+
+	content := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	// a line appended in the middle of the file shifts every following block
+	changed := append(append(append([]byte{}, content[:300*1024]...), []byte("new log line\n")...), content[300*1024:]...)
+
+	for _, tt := range []struct {
+		name   string
+		delta  bool
+		sparse bool
+		opts   []DSOptions
+	}{
+		{"success blocks are reused", true, false, nil},
+		{"success blocks are reused with sparse", true, true, nil},
+		{"success blocks are reused with sha256 and little memory", true, false,
+			[]DSOptions{WithHasher(SHA256Hasher), WithBufferSize(4096), WithMaxMemory(4096)}},
+		{"success whole file copied by default", false, false, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+			dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+			if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+				t.Errorf("error")
+			}
+			defer os.RemoveAll(srcDir)
+			defer os.RemoveAll(dstDir)
+
+			writeFile(fmt.Sprintf("%s/%s", srcDir, "app.log"), string(changed))
+			writeFile(fmt.Sprintf("%s/%s", dstDir, "app.log"), string(content))
+
+			ds, err := New(ctx, srcDir, dstDir, append(tt.opts, WithDelta(tt.delta), WithSparse(tt.sparse))...)
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			if err = ds.DoSync(ctx); err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+
+			got, err := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "app.log"))
+			if err != nil || !bytes.Equal(got, changed) {
+				t.Errorf("destination must be equal to source")
+			}
+			summary := ds.GetSummary()
+			if summary.Updated != 1 || summary.Bytes != int64(len(changed)) {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+			if tt.delta && summary.Matched < int64(len(content))-2*4096 {
+				t.Errorf("most of the file must be reused, matched %d", summary.Matched)
+			}
+			if !tt.delta && summary.Matched != 0 {
+				t.Errorf("must be 0, got %d", summary.Matched)
+			}
+		})
+	}
+}
+
+func TestDosyncDeltaVerified(t *testing.T) {
+	ctx := context.Background()
+	srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+	if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+		t.Errorf("error")
+	}
+	defer os.RemoveAll(srcDir)
+	defer os.RemoveAll(dstDir)
+
+	rnd := rand.New(rand.NewSource(2))
+	content := make([]byte, 16*1024)
+	for i := range content {
+		content[i] = byte(10 + rnd.Intn(200))
+	}
+	// +1 -2 +1 on three bytes keeps the rolling checksum of every block
+	changed := append([]byte{}, content...)
+	for off := 0; off < len(changed); off += 1024 {
+		changed[off]++
+		changed[off+1] -= 2
+		changed[off+2]++
+	}
+	writeFile(fmt.Sprintf("%s/%s", srcDir, "disk.img"), string(changed))
+	writeFile(fmt.Sprintf("%s/%s", dstDir, "disk.img"), string(content))
+
+	ds, err := New(ctx, srcDir, dstDir, WithDelta(true), WithHasher(collidingHasher{}), WithComparator(AlwaysComparator{}))
+	if err != nil {
+		t.Errorf("fail test")
+	}
+	if err = ds.DoSync(ctx); err != nil {
+		t.Errorf("must be nil, got %v", err)
+	}
+	got, err := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "disk.img"))
+	if err != nil || !bytes.Equal(got, changed) {
+		t.Errorf("false block matches must fall back to a whole copy")
+	}
+	if summary := ds.GetSummary(); summary.Matched != 0 || summary.Updated != 1 {
+		t.Errorf("no block must be reused, got %+v", summary)
+	}
+}
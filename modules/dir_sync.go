@@ -2,26 +2,37 @@ package dsync
 
 import (
 	"context"
-	"crypto/md5" //nolint:gosec
 	"errors"
 	"fmt"
 	dsyncerr "github.com/bondhan/sync/modules/errors"
+	dsynclog "github.com/bondhan/sync/modules/logger"
 	"io"
 	"io/fs"
-	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
-const WorkerCount = 20
-
-type result struct {
-	sourcePath string
-	destPath   string
-	err        error
+// Summary holds the outcome of a sync run, Scanned counts the source files which
+// are not filtered out, Bytes the apparent size of the files written to destination
+// and Allocated the disk space they use, smaller when they have holes
+type Summary struct {
+	Scanned   int64 `json:"scanned"`
+	New       int64 `json:"copied"`
+	Updated   int64 `json:"updated"`
+	Unchanged int64 `json:"unchanged"`
+	Skipped   int64 `json:"skipped"`
+	Deleted   int64 `json:"deleted"`
+	Symlinks  int64 `json:"symlinks"`
+	HardLinks int64 `json:"hard_links"`
+	Failed    int64 `json:"failed"`
+	Bytes     int64 `json:"bytes"`
+	Allocated int64 `json:"allocated_bytes"`
+	Matched   int64 `json:"matched_bytes"`
 }
 
 type InputData struct {
@@ -29,6 +40,11 @@ type InputData struct {
 	dstPath string
 	srcSize int64
 	isDir   bool
+	srcInfo fs.FileInfo
+	// linkTarget is the target of a link copied as a link, empty otherwise
+	linkTarget string
+	// linkLeader is the destination an hard linked file is linked to, empty otherwise
+	linkLeader string
 }
 
 type DirSync struct {
@@ -38,8 +54,53 @@ type DirSync struct {
 	AbsSrcRoot        string
 	AbsDstRoot        string
 	TotalFiles        int64
+	TotalScanned      int64
+	TotalNew          int64
+	TotalUpdated      int64
+	TotalUnchanged    int64
+	TotalDeleted      int64
+	TotalSkipped      int64
+	TotalSymlinks     int64
+	TotalHardLinks    int64
+	TotalBytes        int64
+	TotalAllocated    int64
+	TotalMatched      int64
+	startedAt         time.Time
+	endedAt           time.Time
+	runErr            error
+	Failures          []*dsyncerr.FileError
+	ErrorPolicy       ErrorPolicy
+	Retry             RetryPolicy
+	Symlinks          SymlinkMode
+	HardLinks         bool
+	Sparse            bool
+	Delta             bool
+	Resume            bool
+	inodes            map[fileKey]string
+	failedOps         int
 	IsVerbose         bool
+	Logger            dsynclog.Logger
 	CreateEmptyFolder bool
+	Delete            bool
+	DryRun            bool
+	BufferSize        int
+	MaxMemory         int64
+	Fsync             bool
+	PreservePerms     bool
+	PreserveTimes     bool
+	Comparator        Comparator
+	Hasher            Hasher
+	Includes          []string
+	Excludes          []string
+	UseGitignore      bool
+	ignores           *ignoreMatcher
+	Walkers           int
+	Validators        int
+	Copiers           int
+	progress          chan<- Event
+	pendingDirs       []Operation
+	buffers           *bufferPool
+	plan              *Plan
 	lock              sync.Mutex
 }
 
@@ -50,9 +111,14 @@ type DirSyncImpl interface {
 	GetFileSize(fileName string) (int64, error)
 	IsFileReadable(fileName string) (bool, error)
 	IsFileWriteable(fileName string) (bool, error)
-	PrintErrVerbose(any ...interface{})
 	DoSync(ctx context.Context) error
 	GetTotal() int64
+	GetSummary() Summary
+	GetPlan() *Plan
+	GetFailures() []*dsyncerr.FileError
+	GetReport() *Report
+	Plan(ctx context.Context) (*Plan, error)
+	Apply(ctx context.Context, plan *Plan) error
 }
 
 type DSOptions func(*DirSync)
@@ -69,6 +135,188 @@ func WithCreateEmptyFolder(createEmptyFolder bool) DSOptions {
 	}
 }
 
+// WithDelete will remove files and directories in destination which do not exist in source
+func WithDelete(isDelete bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.Delete = isDelete
+	}
+}
+
+// WithDryRun will only plan the changes without writing anything to destination
+func WithDryRun(isDryRun bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.DryRun = isDryRun
+	}
+}
+
+// WithBufferSize will set the size of the buffers used to copy and hash files
+func WithBufferSize(size int) DSOptions {
+	return func(ds *DirSync) {
+		ds.BufferSize = size
+	}
+}
+
+// WithMaxMemory will set the maximum total size of the buffers in flight
+func WithMaxMemory(maxMemory int64) DSOptions {
+	return func(ds *DirSync) {
+		ds.MaxMemory = maxMemory
+	}
+}
+
+// WithFsync will flush every copied file to disk before renaming it over its target
+func WithFsync(isFsync bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.Fsync = isFsync
+	}
+}
+
+// WithPreservePerms will carry over the mode bits of source files and directories
+func WithPreservePerms(preservePerms bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.PreservePerms = preservePerms
+	}
+}
+
+// WithPreserveTimes will carry over the modification and access times of source files and directories
+func WithPreserveTimes(preserveTimes bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.PreserveTimes = preserveTimes
+	}
+}
+
+// WithComparator will set the strategy deciding if an existing destination file is updated
+func WithComparator(comparator Comparator) DSOptions {
+	return func(ds *DirSync) {
+		ds.Comparator = comparator
+	}
+}
+
+// WithHasher will set the hash algorithm used to checksum file contents
+func WithHasher(hasher Hasher) DSOptions {
+	return func(ds *DirSync) {
+		ds.Hasher = hasher
+	}
+}
+
+// WithInclude will only sync the files matching one of the glob patterns, ** matches any
+// number of directories, patterns are evaluated against paths relative to the source root
+func WithInclude(patterns ...string) DSOptions {
+	return func(ds *DirSync) {
+		ds.Includes = append(ds.Includes, patterns...)
+	}
+}
+
+// WithExclude will skip the files and directories matching one of the glob patterns
+func WithExclude(patterns ...string) DSOptions {
+	return func(ds *DirSync) {
+		ds.Excludes = append(ds.Excludes, patterns...)
+	}
+}
+
+// WithGitignore will honor .gitignore files in addition to .syncignore files
+func WithGitignore(useGitignore bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.UseGitignore = useGitignore
+	}
+}
+
+// WithWalkers will set the number of workers inspecting the walked entries
+func WithWalkers(n int) DSOptions {
+	return func(ds *DirSync) {
+		if n > 0 {
+			ds.Walkers = n
+		}
+	}
+}
+
+// WithValidators will set the number of workers comparing source and destination files
+func WithValidators(n int) DSOptions {
+	return func(ds *DirSync) {
+		if n > 0 {
+			ds.Validators = n
+		}
+	}
+}
+
+// WithCopiers will set the number of workers writing to destination
+func WithCopiers(n int) DSOptions {
+	return func(ds *DirSync) {
+		if n > 0 {
+			ds.Copiers = n
+		}
+	}
+}
+
+// WithErrorPolicy will set when failed operations stop the sync, FailFast by default
+func WithErrorPolicy(policy ErrorPolicy) DSOptions {
+	return func(ds *DirSync) {
+		ds.ErrorPolicy = policy
+	}
+}
+
+// WithRetry will retry the reads, writes and stats of a file failing with a transient
+// error (EIO, EAGAIN, ETIMEDOUT, ESTALE), nothing is retried by default
+func WithRetry(policy RetryPolicy) DSOptions {
+	return func(ds *DirSync) {
+		ds.Retry = policy
+	}
+}
+
+// WithSymlinks will set how symbolic links are synced, they are followed by default
+func WithSymlinks(mode SymlinkMode) DSOptions {
+	return func(ds *DirSync) {
+		ds.Symlinks = mode
+	}
+}
+
+// WithHardLinks will recreate in destination the files sharing an inode in source as
+// hard links to a single copy
+func WithHardLinks(hardLinks bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.HardLinks = hardLinks
+	}
+}
+
+// WithSparse will keep the holes of sparse files and turn the blocks full of zeros into holes
+func WithSparse(sparse bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.Sparse = sparse
+	}
+}
+
+// WithDelta will rebuild updated files from the unchanged blocks of the destination file
+// and the changed bytes of the source instead of copying them whole
+func WithDelta(delta bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.Delta = delta
+	}
+}
+
+// WithResume will keep the partial file of an interrupted copy and append to it on the
+// next run once its content is verified against the source
+func WithResume(resume bool) DSOptions {
+	return func(ds *DirSync) {
+		ds.Resume = resume
+	}
+}
+
+// WithLogger will set the logger receiving the leveled records of the sync, when not set
+// a debug level text logger on stderr is used in verbose mode and nothing is logged otherwise
+func WithLogger(logger dsynclog.Logger) DSOptions {
+	return func(ds *DirSync) {
+		ds.Logger = logger
+	}
+}
+
+// WithProgress will send a typed event to the channel as files are discovered, compared,
+// skipped, copied and failed and as bytes are written. The channel is never closed by
+// the sync and must be drained by the caller
+func WithProgress(events chan<- Event) DSOptions {
+	return func(ds *DirSync) {
+		ds.progress = events
+	}
+}
+
 // New will create a directory sync object given the source and destination directories
 func New(ctx context.Context, srcRoot string, dstRoot string, opts ...DSOptions) (DirSyncImpl, error) {
 	absSrc, err := filepath.Abs(srcRoot)
@@ -94,21 +342,44 @@ func New(ctx context.Context, srcRoot string, dstRoot string, opts ...DSOptions)
 		IsVerbose:         false,
 		TotalFiles:        0,
 		CreateEmptyFolder: false,
+		Delete:            false,
+		DryRun:            false,
+		BufferSize:        DefaultBufferSize,
+		MaxMemory:         DefaultMaxMemory,
+		Comparator:        DefaultComparator{},
+		Hasher:            MD5Hasher,
+		ErrorPolicy:       FailFast,
+		Retry:             NoRetry,
+		Symlinks:          SymlinkFollow,
+		Walkers:           runtime.NumCPU(),
+		Validators:        2 * runtime.NumCPU(),
+		Copiers:           runtime.NumCPU(),
+		plan:              &Plan{},
 	}
 
 	for _, opt := range opts {
 		opt(ds)
 	}
+	if ds.Logger == nil {
+		if ds.IsVerbose {
+			ds.Logger = dsynclog.NewText(os.Stderr, dsynclog.LevelDebug)
+		} else {
+			ds.Logger = dsynclog.Nop()
+		}
+	}
+	if err = validatePatterns(append(ds.Includes, ds.Excludes...)); err != nil {
+		return nil, err
+	}
+	if ds.UseGitignore {
+		ds.ignores = newIgnoreMatcher(ds.AbsSrcRoot, SyncIgnoreFile, GitIgnoreFile)
+	} else {
+		ds.ignores = newIgnoreMatcher(ds.AbsSrcRoot, SyncIgnoreFile)
+	}
+	ds.buffers = newBufferPool(ds.BufferSize, ds.MaxMemory)
 
 	return ds, nil
 }
 
-func (ds *DirSync) PrintErrVerbose(any ...interface{}) {
-	if ds.IsVerbose {
-		log.Printf("%+v\n", any)
-	}
-}
-
 // IsEmptyDir will check if given dirName is empty directory
 func (ds *DirSync) IsEmptyDir(dirName string) (bool, error) {
 	file, err := os.Open(dirName)
@@ -118,7 +389,7 @@ func (ds *DirSync) IsEmptyDir(dirName string) (bool, error) {
 	defer func(f *os.File) {
 		err = f.Close()
 		if err != nil {
-			ds.PrintErrVerbose(err)
+			ds.Logger.Warn("fail close directory", "path", dirName, "error", err)
 		}
 	}(file)
 
@@ -137,10 +408,10 @@ func (ds *DirSync) MakeDirIfNotExist(dirName string) error {
 		// if not exist then create it
 		err = os.Mkdir(dirName, 0755)
 		if err != nil && os.IsNotExist(err) {
-			ds.PrintErrVerbose("dirname:", dirName, "Err:", err)
+			ds.Logger.Error("fail create directory", "path", dirName, "error", err)
 			return err
 		}
-		ds.PrintErrVerbose(dirName, "successfully created")
+		ds.Logger.Info("directory created", "path", dirName)
 	}
 	return nil
 }
@@ -155,90 +426,290 @@ func (ds *DirSync) IsFileExist(filename string) bool {
 	return true
 }
 
-// WalkFiles will recursively list all the files and directories of a source root and checks
-// if those files exist in destination root, if not then return the destination and the error
-func (ds *DirSync) walkFiles(ctx context.Context, done <-chan struct{}, count chan<- int64) (<-chan InputData, <-chan error) {
+// walkEntry is a path found by the directory walk, waiting to be inspected by a walker
+type walkEntry struct {
+	path string
+	d    fs.DirEntry
+}
+
+// WalkFiles will recursively list all the files and directories of a source root and send them
+// to the next level together with their destination path, directories are never created here.
+// The directory walk itself is sequential, the entries are inspected by a pool of walkers
+func (ds *DirSync) walkFiles(ctx context.Context, done <-chan struct{}) (<-chan InputData, <-chan error) {
+	entries := make(chan walkEntry)
 	pathData := make(chan InputData)
 	errC := make(chan error, 1)
 
+	// the first walker error stops the directory walk
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var walkerErr error
+
+	walkErrC := make(chan error, 1)
 	go func() {
-		defer close(pathData)
-		// WalkDir will recursively run through the directory for files and dirs
-		errC <- filepath.WalkDir(ds.AbsSrcRoot, func(path string, d fs.DirEntry, err error) error {
+		defer close(entries)
+		// visit is called again by followed links with the paths below the link
+		var visit fs.WalkDirFunc
+		visit = func(path string, d fs.DirEntry, err error) error {
 			if path == ds.AbsSrcRoot {
-				return nil // no need to check the root
+				// nothing can be synced from a source which cannot be read
+				return dsyncerr.Wrap(dsyncerr.ErrSourceUnreadable, err)
+			}
+			// d is nil when the entry could not be read at all, the error below handles it
+			if d != nil && ds.isExcluded(ds.relPath(ds.AbsSrcRoot, path), d.IsDir()) {
+				ds.Logger.Debug("excluded, will be skipped", "path", path)
+				ds.skip(ctx, path, nil)
+				if d.IsDir() {
+					return fs.SkipDir // prune the whole directory
+				}
+				return nil
 			}
 			// check the error
 			if err != nil {
-				// if not about permission error then return it for handling
+				// any other error fails the entry alone, the error policy decides if the walk goes on
 				if !errors.Is(err, fs.ErrPermission) {
-					return err
+					ds.Logger.Error("fail walk, will be skipped", "path", path, "error", err)
+					return ds.skipFailed(ctx, path, err)
 				}
 				// if permission error then skip the file for further processing
-				ds.PrintErrVerbose("Permission Err:", err, path, "will be skipped")
+				ds.Logger.Warn("permission denied, will be skipped", "path", path, "error", err)
+				ds.skip(ctx, path, err)
 				return nil
 			}
+			if isSymlink(d) && ds.Symlinks != SymlinkCopy {
+				return ds.followSymlink(ctx, path, visit)
+			}
 
-			// get the file info
-			f, _err := d.Info()
-			if _err != nil {
-				ds.PrintErrVerbose("Fail getting file info Err:", err, path, "will be skipped")
-				return _err // internal error
+			select {
+			case entries <- walkEntry{path, d}:
+			case <-stop:
+				return walkerErr // set before stop is closed
+			case <-ctx.Done():
+				return dsyncerr.ErrSyncCanceled
+			case <-done:
+				return dsyncerr.ErrSyncCanceled
 			}
-			// prepare the destination path
-			dstPath := fmt.Sprintf("%s%s", ds.AbsDstRoot, strings.TrimPrefix(path, ds.AbsSrcRoot))
-
-			// if it is directory
-			if f.IsDir() {
-				// and check if empty
-				isEmpty, errEmpty := ds.IsEmptyDir(path)
-				if errEmpty != nil { // if we found error during checking, blacklist
-					if !errors.Is(errEmpty, fs.ErrPermission) {
-						return err
-					}
-					ds.PrintErrVerbose("Err:", errEmpty, path, "will be skipped")
-					return nil
-				}
+			return nil
+		}
+		// WalkDir does not descend into a root which is a link, its target is walked instead
+		root := ds.AbsSrcRoot
+		if ds.Symlinks != SymlinkCopy {
+			if real, err := filepath.EvalSymlinks(root); err == nil {
+				root = real
+			}
+		}
+		// WalkDir will recursively run through the directory for files and dirs
+		walkErrC <- filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			return visit(ds.AbsSrcRoot+strings.TrimPrefix(p, root), d, err)
+		})
+	}()
 
-				if isEmpty && !ds.CreateEmptyFolder { // skip if empty directory
-					ds.PrintErrVerbose(path, "is empty folder, will be skipped")
-					return nil
+	var wg sync.WaitGroup
+	wg.Add(ds.Walkers)
+	for i := 0; i < ds.Walkers; i++ {
+		go func() {
+			defer wg.Done()
+			for e := range entries {
+				id, ok, err := ds.inspectEntry(ctx, e.path, e.d)
+				if err != nil && !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+					// the entry alone is left out, the error policy decides if the walk goes on
+					err = ds.skipFailed(ctx, e.path, err)
 				}
-
-				err = ds.MakeDirIfNotExist(dstPath)
 				if err != nil {
-					ds.PrintErrVerbose("fail create directory err:", err)
-					return nil
+					stopOnce.Do(func() {
+						walkerErr = err
+						close(stop)
+					})
+					continue
+				}
+				if !ok {
+					continue
+				}
+				select {
+				case pathData <- id:
+				case <-ctx.Done():
+					return
+				case <-done:
+					return
 				}
-
-				// always skip directory
-				return nil
 			}
+		}()
+	}
 
-			readable, err := ds.IsFileReadable(path)
-			if err != nil {
-				ds.PrintErrVerbose("Readable error:", err, path, "will be skipped")
-				return err // internal error
-			}
+	go func() {
+		wg.Wait()
+		close(pathData)
+		err := <-walkErrC
+		if walkerErr != nil {
+			err = walkerErr
+		}
+		errC <- err
+	}()
 
-			if !readable {
-				ds.PrintErrVerbose(path, "cannot be read, will be skipped")
-				return nil
+	return pathData, errC
+}
+
+// inspectEntry will check if a walked entry needs to be synced, ok is false
+// for entries which are skipped
+func (ds *DirSync) inspectEntry(ctx context.Context, path string, d fs.DirEntry) (InputData, bool, error) {
+	// get the file info
+	var f fs.FileInfo
+	err := ds.retry(ctx, path, func() (err error) {
+		f, err = d.Info()
+		return err
+	})
+	if err != nil {
+		ds.Logger.Error("fail get file info", "path", path, "error", err)
+		return InputData{}, false, err // internal error
+	}
+	// prepare the destination path
+	dstPath := fmt.Sprintf("%s%s", ds.AbsDstRoot, strings.TrimPrefix(path, ds.AbsSrcRoot))
+
+	// links reaching here are copied as links, the content they point to is not read
+	if isSymlink(d) {
+		target, errLink := os.Readlink(path)
+		if errLink != nil {
+			ds.Logger.Warn("fail read symlink, will be skipped", "path", path, "error", errLink)
+			ds.skip(ctx, path, errLink)
+			return InputData{}, false, nil
+		}
+		if _, errStat := os.Stat(path); os.IsNotExist(errStat) {
+			ds.Logger.Warn("dangling symlink, copied as is", "path", path, "target", target)
+		}
+		ds.lock.Lock()
+		ds.TotalScanned++
+		ds.lock.Unlock()
+		ds.emit(ctx, Event{Type: EventDiscovered, Path: path})
+		return InputData{srcPath: path, dstPath: dstPath, srcInfo: f, linkTarget: target}, true, nil
+	}
+
+	// if it is directory
+	if f.IsDir() {
+		// and check if empty
+		isEmpty, errEmpty := ds.IsEmptyDir(path)
+		if errEmpty != nil { // if we found error during checking, blacklist
+			if !errors.Is(errEmpty, fs.ErrPermission) {
+				return InputData{}, false, errEmpty
 			}
+			ds.Logger.Warn("fail read directory, will be skipped", "path", path, "error", errEmpty)
+			ds.skip(ctx, path, errEmpty)
+			return InputData{}, false, nil
+		}
 
-			id := InputData{path, dstPath, f.Size(), d.IsDir()}
-			select {
-			case pathData <- id:
-			case <-ctx.Done():
-				return errors.New("sync canceled")
-			case <-done:
-				return errors.New("sync canceled")
+		if isEmpty && !ds.CreateEmptyFolder { // skip if empty directory
+			ds.Logger.Debug("empty folder, will be skipped", "path", path)
+			ds.skip(ctx, path, nil)
+			return InputData{}, false, nil
+		}
+	} else {
+		readable, errReadable := ds.IsFileReadable(path)
+		if errReadable != nil {
+			ds.Logger.Error("fail check readable", "path", path, "error", errReadable)
+			return InputData{}, false, errReadable // internal error
+		}
+
+		if !readable {
+			ds.Logger.Warn("cannot be read, will be skipped", "path", path)
+			ds.skip(ctx, path, fs.ErrPermission)
+			return InputData{}, false, nil
+		}
+	}
+
+	// directories are not counted so the progress agrees with the summary
+	if !f.IsDir() {
+		ds.lock.Lock()
+		ds.TotalScanned++
+		ds.lock.Unlock()
+		ds.emit(ctx, Event{Type: EventDiscovered, Path: path, Bytes: f.Size()})
+	}
+
+	id := InputData{srcPath: path, dstPath: dstPath, srcSize: f.Size(), isDir: d.IsDir(), srcInfo: f}
+	if ds.HardLinks && !f.IsDir() {
+		id.linkLeader = ds.linkLeader(path, dstPath, f)
+	}
+	return id, true, nil
+}
+
+// deleteExtraneous will recursively walk the destination root and pass a delete operation
+// for every file or directory which does not exist in the source root
+func (ds *DirSync) deleteExtraneous(ctx context.Context, done <-chan struct{}, apply func(Operation) error) error {
+	return filepath.WalkDir(ds.AbsDstRoot, func(path string, d fs.DirEntry, err error) error {
+		select {
+		case <-ctx.Done():
+			return dsyncerr.ErrSyncCanceled
+		case <-done:
+			return dsyncerr.ErrSyncCanceled
+		default:
+		}
+
+		if path == ds.AbsDstRoot {
+			return nil // never delete the root
+		}
+		// d is nil when the entry could not be read at all, the error below handles it
+		if d != nil && ds.isExcluded(ds.relPath(ds.AbsDstRoot, path), d.IsDir()) {
+			// excluded entries are not part of the sync so they are kept
+			if d.IsDir() {
+				return fs.SkipDir
 			}
 			return nil
-		})
-	}()
+		}
+		if err != nil {
+			if !errors.Is(err, fs.ErrPermission) {
+				return err
+			}
+			ds.Logger.Warn("permission denied, will be skipped", "path", path, "error", err)
+			ds.skip(ctx, path, err)
+			return nil
+		}
 
-	return pathData, errC
+		rel := strings.TrimPrefix(path, ds.AbsDstRoot)
+		if target, ok := partialTarget(d.Name()); ok && !d.IsDir() {
+			// a partial file is kept as long as the file it is copied from
+			rel = filepath.Join(filepath.Dir(rel), target)
+		}
+		srcPath := fmt.Sprintf("%s%s", ds.AbsSrcRoot, rel)
+		if _, errStat := os.Lstat(srcPath); !os.IsNotExist(errStat) {
+			return nil // still exists in source
+		}
+
+		op := Operation{Kind: OpDelete, Dst: path, Size: ds.treeSize(path)}
+		if errApply := apply(op); errApply != nil {
+			if errStop := ds.handleFailure(ctx, op, errApply); errStop != nil {
+				return errStop
+			}
+		}
+
+		if d.IsDir() {
+			return filepath.SkipDir // already removed with its content
+		}
+		return nil
+	})
+}
+
+// relPath will return the slash separated path of path relative to root
+func (ds *DirSync) relPath(root, path string) string {
+	return filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(path, root), string(filepath.Separator)))
+}
+
+// treeSize will return the total size of the files under path, or the size of path if it is a file
+func (ds *DirSync) treeSize(path string) int64 {
+	total := int64(0)
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // best effort, unreadable entries are not counted
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if info, errInfo := d.Info(); errInfo == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		ds.Logger.Warn("fail compute size", "path", path, "error", err)
+	}
+	return total
 }
 
 func (ds *DirSync) GetFileSize(fileName string) (int64, error) {
@@ -249,7 +720,7 @@ func (ds *DirSync) GetFileSize(fileName string) (int64, error) {
 	defer func(f *os.File) {
 		err = f.Close()
 		if err != nil {
-			ds.PrintErrVerbose(err)
+			ds.Logger.Warn("fail close file", "path", fileName, "error", err)
 		}
 	}(file)
 
@@ -270,7 +741,7 @@ func (ds *DirSync) IsFileReadable(fileName string) (bool, error) {
 	}
 	err = file.Close()
 	if err != nil {
-		ds.PrintErrVerbose(err)
+		ds.Logger.Warn("fail close file", "path", fileName, "error", err)
 	}
 
 	return true, nil
@@ -286,47 +757,91 @@ func (ds *DirSync) IsFileWriteable(fileName string) (bool, error) {
 	}
 	err = file.Close()
 	if err != nil {
-		ds.PrintErrVerbose(err)
+		ds.Logger.Warn("fail close file", "path", fileName, "error", err)
 	}
 	return true, nil
 }
 
-// Checker will do mostly validation if a file is feasible to be copied
-func (ds *DirSync) fileValidator(ctx context.Context, done <-chan struct{}, paths <-chan InputData, c chan<- result) {
+// isChanged will ask the comparator if the destination file differs from its source
+func (ds *DirSync) isChanged(ctx context.Context, fInput InputData, dstInfo fs.FileInfo) (bool, error) {
+	src := FileState{Path: fInput.srcPath, Size: fInput.srcSize, ModTime: fInput.srcInfo.ModTime()}
+	dst := FileState{Path: fInput.dstPath, Size: dstInfo.Size(), ModTime: dstInfo.ModTime()}
+	var changed bool
+	err := ds.retry(ctx, fInput.srcPath, func() (err error) {
+		changed, err = ds.Comparator.Changed(ctx, src, dst, ds)
+		return err
+	})
+	return changed, err
+}
+
+// fileValidator will do mostly validation if a file is feasible to be copied,
+// new files and files which differ from destination are passed to the next level
+// as copy or update operations, missing directories as mkdir operations
+func (ds *DirSync) fileValidator(ctx context.Context, done <-chan struct{}, paths <-chan InputData, c chan<- Operation) {
 	for fInput := range paths {
-		// fmt.Println(fInput.srcPath, "-", fInput.dstPath)
-		var err error
-		if !fInput.isDir && ds.IsFileExist(fInput.dstPath) {
-			// check the srcSize
-			dstSize, err := ds.GetFileSize(fInput.dstPath)
-			if err == nil {
-				if dstSize == fInput.srcSize {
-					dataSrc, err := ioutil.ReadFile(fInput.srcPath)
-					if err != nil {
-						// skip the file
-						ds.PrintErrVerbose("read src file before md5 err:", err)
-						continue
-					}
-					dataDst, err := ioutil.ReadFile(fInput.dstPath)
-					if err != nil {
-						// skip the file
-						ds.PrintErrVerbose("read dst file before md5 err:", err)
-						continue
-					}
-					if md5.Sum(dataSrc) == md5.Sum(dataDst) { //nolint:gosec
-						// skip the file as identical
-						continue
-					}
-				}
+		op := Operation{
+			Kind:       OpCopy,
+			Src:        fInput.srcPath,
+			Dst:        fInput.dstPath,
+			Size:       fInput.srcSize,
+			Mode:       fInput.srcInfo.Mode(),
+			ModTime:    fInput.srcInfo.ModTime(),
+			AccessTime: accessTime(fInput.srcInfo),
+		}
+		if fInput.linkTarget != "" {
+			if current, errLink := os.Readlink(fInput.dstPath); errLink == nil && current == fInput.linkTarget {
+				// skip the link as identical
+				ds.lock.Lock()
+				ds.TotalUnchanged++
+				ds.lock.Unlock()
+				ds.emit(ctx, Event{Type: EventSkipped, Path: fInput.srcPath})
+				continue
+			}
+			op.Kind, op.Size, op.Target = OpSymlink, 0, fInput.linkTarget
+		} else if fInput.linkLeader != "" {
+			// the leader may not be copied yet, the link is checked once copies are done
+			op.Kind, op.Size, op.Target = OpHardlink, 0, fInput.linkLeader
+		} else if dstInfo, errStat := ds.stat(ctx, fInput.dstPath); fInput.isDir {
+			op.Src, op.Size = "", 0
+			if errStat != nil {
+				op.Kind = OpMkdir
+			} else if ds.attrsDiffer(op, dstInfo) {
+				op.Kind = OpAttrs
+			} else {
+				continue // nothing to do
+			}
+		} else if errStat == nil {
+			changed, errChanged := ds.isChanged(ctx, fInput, dstInfo)
+			if errChanged != nil {
+				// skip the file
+				ds.Logger.Warn("fail compare, will be skipped", "path", fInput.srcPath, "error", errChanged)
+				ds.skip(ctx, fInput.srcPath, errChanged)
+				continue
+			}
+			ds.emit(ctx, Event{Type: EventCompared, Path: fInput.srcPath, Bytes: fInput.srcSize})
+			switch {
+			case changed:
+				op.Kind = OpUpdate
+			case ds.attrsDiffer(op, dstInfo):
+				// identical content, only the attributes are refreshed
+				op.Kind, op.Src, op.Size = OpAttrs, "", 0
+				ds.lock.Lock()
+				ds.TotalUnchanged++
+				ds.lock.Unlock()
+			default:
+				// skip the file as identical
+				ds.lock.Lock()
+				ds.TotalUnchanged++
+				ds.lock.Unlock()
+				ds.emit(ctx, Event{Type: EventSkipped, Path: fInput.srcPath})
+				continue
 			}
-
-			ds.PrintErrVerbose("err get size:", err)
-			continue // skip
 		}
 		select {
-		// list of files need to be copied
-		case c <- result{fInput.srcPath, fInput.dstPath, err}:
-			ds.PrintErrVerbose("sent", result{fInput.srcPath, fInput.dstPath, err})
+		// list of operations need to be applied
+		case c <- op:
+			ds.Logger.Debug("operation queued", "op", op.Kind, "path", fInput.srcPath, "bytes", op.Size)
+			ds.emit(ctx, Event{Type: EventQueued, Op: op.Kind, Path: fInput.srcPath, Bytes: op.Size})
 		case <-ctx.Done():
 			return
 		case <-done:
@@ -335,31 +850,396 @@ func (ds *DirSync) fileValidator(ctx context.Context, done <-chan struct{}, path
 	}
 }
 
+// applyOperation will perform the operation in destination
+func (ds *DirSync) applyOperation(ctx context.Context, op Operation) error {
+	switch op.Kind {
+	case OpMkdir:
+		if err := ds.retry(ctx, op.Dst, func() error { return os.MkdirAll(op.Dst, 0755) }); err != nil {
+			ds.Logger.Error("fail create directory", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+		ds.Logger.Info("directory created", "op", op.Kind, "path", op.Dst)
+		ds.deferDir(op)
+	case OpCopy, OpUpdate:
+		// parent directory may not be created yet as levels run concurrently
+		if err := ds.retry(ctx, op.Dst, func() error { return os.MkdirAll(filepath.Dir(op.Dst), 0755) }); err != nil {
+			ds.Logger.Error("fail create directory", "op", op.Kind, "path", filepath.Dir(op.Dst), "error", err)
+			return err
+		}
+
+		// a failed copy leaves no temporary file so it is attempted again from the start
+		if err := ds.retry(ctx, op.Src, func() error { return ds.copyFile(ctx, op) }); err != nil {
+			ds.Logger.Error("fail copy file", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+		ds.Logger.Info("file copied", "op", op.Kind, "path", op.Dst, "bytes", op.Size)
+		if info, err := os.Stat(op.Dst); err == nil {
+			ds.lock.Lock()
+			ds.TotalAllocated += allocatedSize(info)
+			ds.lock.Unlock()
+		}
+	case OpSymlink:
+		if err := ds.retry(ctx, op.Dst, func() error { return os.MkdirAll(filepath.Dir(op.Dst), 0755) }); err != nil {
+			ds.Logger.Error("fail create directory", "op", op.Kind, "path", filepath.Dir(op.Dst), "error", err)
+			return err
+		}
+		if err := ds.retry(ctx, op.Dst, func() error {
+			return ds.createInPlace(op.Dst, func(tmpName string) error { return os.Symlink(op.Target, tmpName) })
+		}); err != nil {
+			ds.Logger.Error("fail create symlink", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+		ds.Logger.Info("symlink created", "op", op.Kind, "path", op.Dst, "target", op.Target)
+	case OpHardlink:
+		if err := ds.retry(ctx, op.Dst, func() error {
+			return ds.createInPlace(op.Dst, func(tmpName string) error { return os.Link(op.Target, tmpName) })
+		}); err != nil {
+			ds.Logger.Error("fail create hard link", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+		ds.Logger.Info("hard link created", "op", op.Kind, "path", op.Dst, "target", op.Target)
+	case OpDelete:
+		if err := ds.retry(ctx, op.Dst, func() error { return os.RemoveAll(op.Dst) }); err != nil {
+			ds.Logger.Error("fail delete", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+		ds.Logger.Info("deleted", "op", op.Kind, "path", op.Dst, "bytes", op.Size)
+	case OpAttrs:
+		dstInfo, err := ds.stat(ctx, op.Dst)
+		if err != nil {
+			ds.Logger.Error("fail stat", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+		if dstInfo.IsDir() {
+			ds.deferDir(op)
+			break
+		}
+		if err = ds.retry(ctx, op.Dst, func() error { return ds.setAttrs(op.Dst, op) }); err != nil {
+			ds.Logger.Error("fail set attributes", "op", op.Kind, "path", op.Dst, "error", err)
+			return err
+		}
+	}
+
+	ds.lock.Lock()
+	switch op.Kind {
+	case OpCopy:
+		ds.TotalFiles++
+		ds.TotalNew++
+		ds.TotalBytes += op.Size
+	case OpUpdate:
+		ds.TotalFiles++
+		ds.TotalUpdated++
+		ds.TotalBytes += op.Size
+	case OpDelete:
+		ds.TotalDeleted++
+	case OpSymlink:
+		ds.TotalSymlinks++
+	case OpHardlink:
+		ds.TotalHardLinks++
+	}
+	ds.lock.Unlock()
+
+	if op.Kind != OpAttrs {
+		ds.deferParent(op)
+	}
+
+	switch op.Kind {
+	case OpCopy, OpUpdate, OpSymlink, OpHardlink:
+		ds.emit(ctx, Event{Type: EventCopied, Op: op.Kind, Path: op.Src, Bytes: op.Size})
+	case OpDelete:
+		ds.emit(ctx, Event{Type: EventDeleted, Op: op.Kind, Path: op.Dst, Bytes: op.Size})
+	}
+	return nil
+}
+
 func (ds *DirSync) GetTotal() int64 {
 	ds.lock.Lock()
 	defer ds.lock.Unlock()
 	return ds.TotalFiles
 }
 
-// DoSync will synchronize source and destination folders
-// if context cancel is called then all operation stop accordingly
-func (ds *DirSync) DoSync(ctx context.Context) error {
-	done := make(chan struct{})
-	defer close(done) // if close, all downstream will abandon its work
+// GetSummary will return the number of new, updated, unchanged and deleted files
+func (ds *DirSync) GetSummary() Summary {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return Summary{
+		Scanned:   ds.TotalScanned,
+		New:       ds.TotalNew,
+		Updated:   ds.TotalUpdated,
+		Unchanged: ds.TotalUnchanged,
+		Skipped:   ds.TotalSkipped,
+		Deleted:   ds.TotalDeleted,
+		Symlinks:  ds.TotalSymlinks,
+		HardLinks: ds.TotalHardLinks,
+		Failed:    int64(len(ds.Failures)),
+		Bytes:     ds.TotalBytes,
+		Allocated: ds.TotalAllocated,
+		Matched:   ds.TotalMatched,
+	}
+}
 
-	count := make(chan int64)
-	defer close(count)
+// GetFailures will return a copy of the failures recorded per file
+func (ds *DirSync) GetFailures() []*dsyncerr.FileError {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return append([]*dsyncerr.FileError(nil), ds.Failures...)
+}
+
+// skip will count an entry which is excluded, unreadable or cannot be compared
+func (ds *DirSync) skip(ctx context.Context, path string, err error) {
+	ds.lock.Lock()
+	ds.TotalSkipped++
+	if err != nil {
+		// the file is left out of the sync, the transfer is partial
+		ds.Failures = append(ds.Failures, &dsyncerr.FileError{Op: "skip", Path: path, Err: err})
+	}
+	ds.lock.Unlock()
+	ds.emit(ctx, Event{Type: EventSkipped, Path: path, Err: err})
+}
+
+// resetRun will clear what a previous run on this instance counted and recorded, a leader
+// of a previous run must not become a link to itself either
+func (ds *DirSync) resetRun() {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	ds.TotalFiles, ds.TotalScanned, ds.TotalNew, ds.TotalUpdated, ds.TotalUnchanged = 0, 0, 0, 0, 0
+	ds.TotalDeleted, ds.TotalSkipped, ds.TotalSymlinks, ds.TotalHardLinks = 0, 0, 0, 0
+	ds.TotalBytes, ds.TotalAllocated, ds.TotalMatched = 0, 0, 0
+	ds.Failures, ds.failedOps = nil, 0
+	ds.pendingDirs = nil
+	ds.inodes = make(map[fileKey]string)
+}
+
+// skipFailed will leave out an entry which cannot be inspected and record the failure,
+// it returns an error only when the error policy stops the sync
+func (ds *DirSync) skipFailed(ctx context.Context, path string, err error) error {
+	ds.lock.Lock()
+	ds.TotalSkipped++
+	ds.lock.Unlock()
+	return ds.handleFailure(ctx, Operation{Kind: opSkip, Src: path}, err)
+}
+
+// recordFailure will keep the failure of an operation, a canceled sync is not a file failure
+func (ds *DirSync) recordFailure(ctx context.Context, op Operation, err error) error {
+	if errors.Is(err, dsyncerr.ErrSyncCanceled) {
+		return err
+	}
+	path := op.Src
+	if path == "" {
+		path = op.Dst
+	}
+	if errors.Is(err, syscall.ENOSPC) {
+		err = dsyncerr.Wrap(dsyncerr.ErrDestinationFull, err)
+	}
+	fileErr := &dsyncerr.FileError{Op: string(op.Kind), Path: path, Err: err}
+
+	ds.lock.Lock()
+	ds.Failures = append(ds.Failures, fileErr)
+	ds.failedOps++
+	ds.lock.Unlock()
+
+	ds.emit(ctx, Event{Type: EventFailed, Op: op.Kind, Path: path, Err: err})
+	return fileErr
+}
+
+// GetPlan will return the operations recorded during a dry run
+func (ds *DirSync) GetPlan() *Plan {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return ds.plan
+}
+
+// DoSync will synchronize source and destination folders, in dry run mode the
+// changes are only planned and can be retrieved with GetPlan
+// if context cancel is called then all operation stop accordingly. Files left out because
+// they cannot be read make the run return ErrPartialTransfer once everything else is synced
+func (ds *DirSync) DoSync(ctx context.Context) (err error) {
+	ds.lock.Lock()
+	ds.startedAt = time.Now()
+	ds.lock.Unlock()
+	defer func() {
+		ds.lock.Lock()
+		ds.endedAt, ds.runErr = time.Now(), err
+		ds.lock.Unlock()
+	}()
+
+	if ds.DryRun {
+		plan, err := ds.Plan(ctx)
+		if err != nil {
+			return err
+		}
+		ds.lock.Lock()
+		ds.plan = plan
+		ds.lock.Unlock()
+		return ds.partialErr()
+	}
+
+	// leftovers of an interrupted run are never valid destination files
+	if err := ds.cleanTempFiles(ctx); err != nil {
+		ds.Logger.Error("fail clean temporary files", "path", ds.AbsDstRoot, "error", err)
+		return err
+	}
+
+	err = ds.run(ctx, func(op Operation) error {
+		return ds.applyOperation(ctx, op)
+	})
+	if errors.Is(err, dsyncerr.ErrPartialTransfer) {
+		return ds.partialErr() // aborted by the error policy, list every failure so far
+	}
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return dsyncerr.ErrSyncCanceled
+	}
+	if err = ds.finalizeDirs(ctx); err != nil && !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+		return err
+	}
+	return ds.partialErr()
+}
+
+// applyHardLinks will apply the hard link operations once their targets are written,
+// a link already in place is kept unless its target was replaced during the run
+func (ds *DirSync) applyHardLinks(ctx context.Context, links []Operation, written map[string]bool, apply func(Operation) error) error {
+	for _, op := range links {
+		if ctx.Err() != nil {
+			return dsyncerr.ErrSyncCanceled
+		}
+		if !written[op.Target] && isLinked(op) {
+			ds.lock.Lock()
+			ds.TotalUnchanged++
+			ds.lock.Unlock()
+			ds.emit(ctx, Event{Type: EventSkipped, Path: op.Src})
+			continue
+		}
+		if err := apply(op); err != nil {
+			if err = ds.handleFailure(ctx, op, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleFailure will record the failure of op and return an error when the sync must stop,
+// either because it is canceled or because the error policy limit is reached
+func (ds *DirSync) handleFailure(ctx context.Context, op Operation, err error) error {
+	err = ds.recordFailure(ctx, op, err)
+	if errors.Is(err, dsyncerr.ErrSyncCanceled) {
+		return err
+	}
+
+	ds.lock.Lock()
+	failed := ds.failedOps
+	ds.lock.Unlock()
+	if ds.ErrorPolicy.stops(failed) {
+		if failed > 1 {
+			ds.Logger.Error("too many errors, sync aborted", "errors", failed)
+		}
+		return err
+	}
+	return nil
+}
+
+// partialErr will return every failure of the run in a MultiError, which matches
+// ErrPartialTransfer, or nil if no file was left out of the sync
+func (ds *DirSync) partialErr() error {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	if len(ds.Failures) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(ds.Failures))
+	for _, f := range ds.Failures {
+		errs = append(errs, f)
+	}
+	return &dsyncerr.MultiError{Errors: errs}
+}
+
+// Plan will walk and validate source and destination folders and return the operations
+// needed to synchronize them without writing anything to destination
+func (ds *DirSync) Plan(ctx context.Context) (*Plan, error) {
+	plan := &Plan{SrcRoot: ds.AbsSrcRoot, DstRoot: ds.AbsDstRoot}
+	var lock sync.Mutex
+	err := ds.run(ctx, func(op Operation) error {
+		lock.Lock()
+		defer lock.Unlock()
+		plan.Operations = append(plan.Operations, op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Apply will perform the operations of a plan in order, the plan must be made
+// for the same source and destination folders
+func (ds *DirSync) Apply(ctx context.Context, plan *Plan) error {
+	if plan.SrcRoot != ds.AbsSrcRoot || plan.DstRoot != ds.AbsDstRoot {
+		return dsyncerr.ErrPlanMismatch
+	}
+
+	for _, op := range plan.Operations {
+		select {
+		case <-ctx.Done():
+			return dsyncerr.ErrSyncCanceled
+		default:
+		}
+
+		if !isInside(op.Dst, ds.AbsDstRoot) || (op.Src != "" && !isInside(op.Src, ds.AbsSrcRoot)) ||
+			(op.Kind == OpHardlink && !isInside(op.Target, ds.AbsDstRoot)) {
+			ds.Logger.Error("invalid operation", "op", op.Kind, "path", op.Dst)
+			return dsyncerr.ErrInvalidOperation
+		}
+		if err := ds.applyOperation(ctx, op); err != nil {
+			if errStop := ds.handleFailure(ctx, op, err); errStop != nil {
+				if errors.Is(errStop, dsyncerr.ErrSyncCanceled) {
+					return errStop
+				}
+				return ds.partialErr()
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return dsyncerr.ErrSyncCanceled
+	}
+	if err := ds.finalizeDirs(ctx); err != nil && !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+		return err
+	}
+	return ds.partialErr()
+}
+
+// isInside will check if path is located below root
+func isInside(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// run is the pipeline shared by DoSync and Plan, every operation produced
+// by the levels below is passed to apply
+func (ds *DirSync) run(ctx context.Context, apply func(Operation) error) error {
+	ds.resetRun()
+
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	abort := func() {
+		doneOnce.Do(func() { close(done) })
+	}
+	defer abort() // if close, all downstream will abandon its work
 
 	// level 1, walk the source directory recursively
-	pathdata, errc := ds.walkFiles(ctx, done, count)
+	pathdata, errc := ds.walkFiles(ctx, done)
 
-	res := make(chan result)
+	res := make(chan Operation)
 	var wg sync.WaitGroup
 
 	// number of check workers to validate if need to do copy or no
-	const numCheckers = WorkerCount
-	wg.Add(numCheckers)
-	for i := 0; i < numCheckers; i++ {
+	wg.Add(ds.Validators)
+	for i := 0; i < ds.Validators; i++ {
 		go func() {
 			//level 2 validate if file is valid for copy to destination
 			ds.fileValidator(ctx, done, pathdata, res) // HLc
@@ -371,51 +1251,75 @@ func (ds *DirSync) DoSync(ctx context.Context) error {
 		close(res)
 	}()
 
-	// set a goroutine to listen to number of changes
-	go func() {
-		for {
-			select {
-			case cnt := <-count:
-				ds.lock.Lock()
-				ds.TotalFiles = cnt
-				ds.lock.Unlock()
-			case <-ctx.Done():
-				return
-			case <-done:
-				return
+	//level 3 apply (or only record to the plan) the operations with a pool of copiers,
+	// every failure is recorded per file and the error policy decides when to abort the levels above
+	var copyErr error
+	var errOnce sync.Once
+	var copyWg sync.WaitGroup
+	// hard links wait for the copy of their target, written holds the copied destinations
+	var linkLock sync.Mutex
+	var links []Operation
+	written := make(map[string]bool)
+	copyWg.Add(ds.Copiers)
+	for i := 0; i < ds.Copiers; i++ {
+		go func() {
+			defer copyWg.Done()
+			for op := range res {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if op.Kind == OpHardlink {
+					linkLock.Lock()
+					links = append(links, op)
+					linkLock.Unlock()
+					continue
+				}
+				if err := apply(op); err != nil {
+					if err = ds.handleFailure(ctx, op, err); err != nil {
+						errOnce.Do(func() {
+							copyErr = err
+							abort()
+						})
+						return
+					}
+				} else if ds.HardLinks {
+					linkLock.Lock()
+					written[op.Dst] = true
+					linkLock.Unlock()
+				}
 			}
-		}
-	}()
+		}()
+	}
+	copyWg.Wait()
+	if copyErr != nil {
+		return copyErr
+	}
 
-	cnt := int64(0)
-	//level 3 copy action
-	for r := range res {
-		if r.err != nil {
-			ds.PrintErrVerbose("receive r.err:", r.err)
-			continue
-		}
+	// Check whether the Walk failed.
+	if err := <-errc; err != nil {
+		ds.Logger.Error("fail walk source", "path", ds.AbsSrcRoot, "error", err)
+		return err
+	}
+	// validators drop the queued entries once canceled, even when the walk already ended
+	if ctx.Err() != nil {
+		return dsyncerr.ErrSyncCanceled
+	}
 
-		input, err := ioutil.ReadFile(r.sourcePath)
-		if err != nil {
-			ds.PrintErrVerbose("Error Read input:", err)
-			return err
-		}
+	if err := ds.applyHardLinks(ctx, links, written, apply); err != nil {
+		return err
+	}
 
-		err = ioutil.WriteFile(r.destPath, input, 0755) //nolint:gosec
-		if err != nil {
-			ds.PrintErrVerbose("Error creating", r.destPath, "Err:", err)
+	// level 4 remove destination entries which no longer exist in source
+	if ds.Delete {
+		if err := ds.deleteExtraneous(ctx, done, apply); err != nil {
+			ds.Logger.Error("fail delete extraneous entries", "path", ds.AbsDstRoot, "error", err)
 			return err
 		}
-		cnt++
-		count <- cnt
 	}
-
-	// Check whether the Walk failed.
-	if err := <-errc; err != nil {
-		ds.PrintErrVerbose("walkFiles err:", err)
-		return err
+	if ctx.Err() != nil {
+		return dsyncerr.ErrSyncCanceled
 	}
-	count <- cnt
-	// Return err
 	return nil
 }
@@ -1,10 +1,13 @@
 package dsync
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	dsyncerr "github.com/bondhan/sync/modules/errors"
+	dsynclog "github.com/bondhan/sync/modules/logger"
 	"io/fs"
 	"log"
 	"math/rand"
@@ -482,11 +485,678 @@ func TestDosync(t *testing.T) {
 		if err != nil {
 			t.Errorf("fail test")
 		}
+		// an unreadable file is left out and reported, root can still read it
+		readable, _ := ds.IsFileReadable(targetSrc)
 		err = ds.DoSync(ctx)
 		fmt.Println("err:", err)
+		if readable && err != nil {
+			t.Errorf("must be nil")
+		}
+		if !readable && !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+			t.Errorf("must be ErrPartialTransfer, got %v", err)
+		}
+	})
+
+}
+
+func TestDosyncUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success update file with different size", func(t *testing.T) {
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, "update")
+		writeFile(targetSrc, "hello world")
+
+		targetDest := fmt.Sprintf("%s/%s", destinationDir, "update")
+		writeFile(targetDest, "hello")
+
+		defer func(t, d string) {
+			os.RemoveAll(t)
+			os.RemoveAll(d)
+		}(targetSrc, targetDest)
+
+		ds, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(ctx)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+
+		data, err := os.ReadFile(targetDest)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if string(data) != "hello world" {
+			t.Errorf("destination must be updated")
+		}
+		if ds.GetSummary().Updated != 1 {
+			t.Errorf("must be 1 updated file")
+		}
+	})
+
+	t.Run("success unchanged file is not copied", func(t *testing.T) {
+		targetDest := fmt.Sprintf("%s/%s", destinationDir, "unchanged")
+		writeFile(targetDest, "hello")
+
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, "unchanged")
+		writeFile(targetSrc, "hello")
+		past := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(targetSrc, past, past); err != nil {
+			t.Errorf("must be nil")
+		}
+
+		defer func(t, d string) {
+			os.RemoveAll(t)
+			os.RemoveAll(d)
+		}(targetSrc, targetDest)
+
+		ds, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(ctx)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+
+		summary := ds.GetSummary()
+		if summary.Unchanged != 1 || summary.New != 0 || summary.Updated != 0 {
+			t.Errorf("must be 1 unchanged file, got %+v", summary)
+		}
+	})
+}
+
+func TestDosyncDelete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success delete extraneous file and directory", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		extraFile := fmt.Sprintf("%s/%s", dstDir, randomString(5))
+		writeFile(extraFile, "stale")
+
+		extraDir := fmt.Sprintf("%s/%s", dstDir, randomString(5))
+		if err := ensureDir(extraDir); err != nil {
+			t.Errorf("error")
+		}
+		writeFile(fmt.Sprintf("%s/%s", extraDir, "stale"), "stale")
+
+		targetSrc := fmt.Sprintf("%s/%s", srcDir, "keep")
+		writeFile(targetSrc, "keep")
+
+		ds, err := New(ctx, srcDir, dstDir, WithDelete(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(ctx)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+
+		if ds.IsFileExist(extraFile) || ds.IsFileExist(extraDir) {
+			t.Errorf("extraneous entries must be deleted")
+		}
+		if !ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "keep")) {
+			t.Errorf("synced file must be kept")
+		}
+		if ds.GetSummary().Deleted != 2 {
+			t.Errorf("must be 2 deleted entries")
+		}
+	})
+
+	t.Run("fail canceled context", func(t *testing.T) {
+		extraFile := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		writeFile(extraFile, "stale")
+		defer func(f string) {
+			os.RemoveAll(f)
+		}(extraFile)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		ds, err := New(cctx, sourceDir, destinationDir, WithDelete(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(cctx)
+		if !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("err must be %s", dsyncerr.ErrSyncCanceled)
+		}
+		if !ds.IsFileExist(extraFile) {
+			t.Errorf("file must not be deleted")
+		}
+	})
+}
+
+func TestDosyncDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success plan without writing", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		if err := ensureDir(fmt.Sprintf("%s/%s", srcDir, "sub")); err != nil {
+			t.Errorf("error")
+		}
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "sub/new"), "hello")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "update"), "hello world")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "update"), "hello")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "stale"), "stale")
+
+		ds, err := New(ctx, srcDir, dstDir, WithDelete(true), WithDryRun(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(ctx)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+
+		plan := ds.GetPlan()
+		if plan.Count(OpMkdir) != 1 || plan.Count(OpCopy) != 1 || plan.Count(OpUpdate) != 1 || plan.Count(OpDelete) != 1 {
+			t.Errorf("unexpected plan %+v", plan.Operations)
+		}
+		if plan.Bytes(OpCopy) != 5 || plan.Bytes(OpUpdate) != 11 || plan.Bytes(OpDelete) != 5 {
+			t.Errorf("unexpected plan bytes %+v", plan.Operations)
+		}
+		if ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "sub")) {
+			t.Errorf("directory must not be created")
+		}
+		if !ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "stale")) {
+			t.Errorf("file must not be deleted")
+		}
+		data, _ := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "update"))
+		if string(data) != "hello" {
+			t.Errorf("file must not be updated")
+		}
+	})
+}
+
+func TestPlanApply(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success plan, serialize and apply", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "new"), "hello")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "stale"), "stale")
+
+		ds, err := New(ctx, srcDir, dstDir, WithDelete(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		plan, err := ds.Plan(ctx)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if len(plan.Operations) != 2 {
+			t.Errorf("must be 2 operations")
+		}
+		if ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "new")) {
+			t.Errorf("plan must not write")
+		}
+
+		data, err := json.Marshal(plan)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		var loaded Plan
+		if err = json.Unmarshal(data, &loaded); err != nil {
+			t.Errorf("must be nil")
+		}
+
+		err = ds.Apply(ctx, &loaded)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if !ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "new")) {
+			t.Errorf("file must be copied")
+		}
+		if ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "stale")) {
+			t.Errorf("file must be deleted")
+		}
+	})
+
+	t.Run("fail plan for other destination", func(t *testing.T) {
+		ds, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.Apply(ctx, &Plan{SrcRoot: sourceDir, DstRoot: "/"})
+		if !errors.Is(err, dsyncerr.ErrPlanMismatch) {
+			t.Errorf("err must be %s", dsyncerr.ErrPlanMismatch)
+		}
+	})
+
+	t.Run("fail operation outside of destination", func(t *testing.T) {
+		ds, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		plan := &Plan{
+			SrcRoot:    sourceDir,
+			DstRoot:    destinationDir,
+			Operations: []Operation{{Kind: OpDelete, Dst: fmt.Sprintf("%s/../etc", destinationDir)}},
+		}
+		err = ds.Apply(ctx, plan)
+		if !errors.Is(err, dsyncerr.ErrInvalidOperation) {
+			t.Errorf("err must be %s", dsyncerr.ErrInvalidOperation)
+		}
+	})
+
+	t.Run("fail hard link to a file outside of destination", func(t *testing.T) {
+		ds, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		plan := &Plan{
+			SrcRoot: sourceDir,
+			DstRoot: destinationDir,
+			Operations: []Operation{{Kind: OpHardlink, Dst: fmt.Sprintf("%s/%s", destinationDir, "passwd"),
+				Target: "/etc/passwd"}},
+		}
+		err = ds.Apply(ctx, plan)
+		if !errors.Is(err, dsyncerr.ErrInvalidOperation) {
+			t.Errorf("err must be %s", dsyncerr.ErrInvalidOperation)
+		}
+		if _, err = os.Lstat(fmt.Sprintf("%s/%s", destinationDir, "passwd")); !os.IsNotExist(err) {
+			t.Errorf("hard link must not be created")
+		}
+	})
+}
+
+func TestDosyncPreserve(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success preserve mode and times", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.Chmod(fmt.Sprintf("%s/%s", d, "sub"), 0755)
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		sub := fmt.Sprintf("%s/%s", srcDir, "sub")
+		if err := ensureDir(sub); err != nil {
+			t.Errorf("error")
+		}
+		file := fmt.Sprintf("%s/%s", sub, "file")
+		writeFile(file, "hello", 0640)
+
+		past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		if os.Chtimes(file, past, past) != nil || os.Chtimes(sub, past, past) != nil || os.Chmod(sub, 0555) != nil {
+			t.Errorf("error")
+		}
+		defer os.Chmod(sub, 0755)
+
+		ds, err := New(ctx, srcDir, dstDir, WithPreservePerms(true), WithPreserveTimes(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil, err: %s", err)
+		}
+
+		fileInfo, err := os.Stat(fmt.Sprintf("%s/%s", dstDir, "sub/file"))
+		if err != nil {
+			t.Errorf("must be nil")
+		} else if fileInfo.Mode().Perm() != 0640 || !fileInfo.ModTime().Equal(past) {
+			t.Errorf("file attributes must be preserved, got %s %s", fileInfo.Mode(), fileInfo.ModTime())
+		}
+
+		dirInfo, err := os.Stat(fmt.Sprintf("%s/%s", dstDir, "sub"))
+		if err != nil {
+			t.Errorf("must be nil")
+		} else if dirInfo.Mode().Perm() != 0555 || !dirInfo.ModTime().Equal(past) {
+			t.Errorf("directory attributes must be preserved, got %s %s", dirInfo.Mode(), dirInfo.ModTime())
+		}
+	})
+
+	t.Run("success directory times kept when its content changes", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		sub := fmt.Sprintf("%s/%s", srcDir, "sub")
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil || ensureDir(sub) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+		writeFile(fmt.Sprintf("%s/%s", sub, "a"), "hello")
+
+		past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		run := func(step string, opts ...DSOptions) {
+			// the source directory keeps its time whatever is written into it
+			if os.Chtimes(sub, past, past) != nil {
+				t.Errorf("error")
+			}
+			ds, err := New(ctx, srcDir, dstDir, append(opts, WithPreservePerms(true), WithPreserveTimes(true))...)
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			if err = ds.DoSync(ctx); err != nil {
+				t.Errorf("must be nil, err: %s", err)
+			}
+			dirInfo, err := os.Stat(fmt.Sprintf("%s/%s", dstDir, "sub"))
+			if err != nil || !dirInfo.ModTime().Equal(past) {
+				t.Errorf("directory time must be preserved after %s", step)
+			}
+		}
+
+		run("the first copy")
+		writeFile(fmt.Sprintf("%s/%s", sub, "b"), "world")
+		run("a file added")
+		if os.Remove(fmt.Sprintf("%s/%s", sub, "b")) != nil {
+			t.Errorf("error")
+		}
+		run("a file deleted", WithDelete(true))
+	})
+}
+
+func TestDosyncWorkers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success ignore non positive pool size", func(t *testing.T) {
+		impl, err := New(ctx, sourceDir, destinationDir, WithWalkers(0), WithValidators(-1), WithCopiers(3))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		ds := impl.(*DirSync)
+		if ds.Walkers < 1 || ds.Validators < 1 || ds.Copiers != 3 {
+			t.Errorf("unexpected pool sizes %d %d %d", ds.Walkers, ds.Validators, ds.Copiers)
+		}
+	})
+
+	t.Run("success single worker per level", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		for i := 0; i < 50; i++ {
+			writeFile(fmt.Sprintf("%s/%d", srcDir, i), randomString(10))
+		}
+
+		ds, err := New(ctx, srcDir, dstDir, WithWalkers(1), WithValidators(1), WithCopiers(1))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+		if ds.GetTotal() != 50 {
+			t.Errorf("must be 50 files")
+		}
+	})
+}
+
+func TestDosyncParallelCopy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success many copiers", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		for i := 0; i < 10; i++ {
+			sub := fmt.Sprintf("%s/%d", srcDir, i)
+			if err := ensureDir(sub); err != nil {
+				t.Errorf("error")
+			}
+			for j := 0; j < 20; j++ {
+				writeFile(fmt.Sprintf("%s/%d", sub, j), randomString(10))
+			}
+		}
+
+		ds, err := New(ctx, srcDir, dstDir, WithCopiers(16))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+		if ds.GetTotal() != 200 || ds.GetSummary().New != 200 {
+			t.Errorf("must be 200 files, got %d", ds.GetTotal())
+		}
+		if len(ds.GetFailures()) != 0 {
+			t.Errorf("must be no failure")
+		}
+	})
+
+	t.Run("fail copy is recorded per file", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil || ensureDir(fmt.Sprintf("%s/%s", srcDir, "sub")) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		target := fmt.Sprintf("%s/%s", srcDir, "sub/file")
+		writeFile(target, "hello")
+		// a file in place of the destination directory makes the copy fail
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "sub"), "not a directory")
+
+		ds, err := New(ctx, srcDir, dstDir, WithCopiers(4))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(ctx)
+		var fileErr *dsyncerr.FileError
+		if !errors.As(err, &fileErr) {
+			t.Errorf("err must be a file error, got %v", err)
+		}
+		failures := ds.GetFailures()
+		if len(failures) != 1 || failures[0].Path != target {
+			t.Errorf("failure must be recorded for %s, got %v", target, failures)
+		}
+	})
+}
+
+func TestDosyncLogger(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success copied files are logged", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "a"), "hello")
+
+		out := &bytes.Buffer{}
+		ds, err := New(ctx, srcDir, dstDir, WithLogger(dsynclog.NewJSON(out, dsynclog.LevelInfo)))
 		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
 			t.Errorf("must be nil")
 		}
+
+		var record map[string]interface{}
+		if err = json.Unmarshal(out.Bytes(), &record); err != nil {
+			t.Fatalf("must be one json record, got %q", out.String())
+		}
+		if record["msg"] != "file copied" || record["op"] != string(OpCopy) || record["bytes"] != float64(5) {
+			t.Errorf("unexpected record %v", record)
+		}
+	})
+
+	t.Run("success quiet by default", func(t *testing.T) {
+		impl, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if impl.(*DirSync).Logger == nil {
+			t.Errorf("must not be nil")
+		}
+	})
+}
+
+func TestDosyncErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fail same source and destination is an invalid usage", func(t *testing.T) {
+		_, err := New(ctx, sourceDir, sourceDir)
+		if !errors.Is(err, dsyncerr.ErrInvalidUsage) {
+			t.Errorf("must be ErrInvalidUsage, got %v", err)
+		}
+	})
+
+	t.Run("fail source unreadable", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.Chmod(s, 0755)
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "a"), "hello")
+		if os.Chmod(srcDir, 0000) != nil {
+			t.Errorf("error")
+		}
+
+		ds, err := New(ctx, srcDir, dstDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if _, errOpen := os.ReadDir(srcDir); errOpen == nil {
+			t.Skip("source is still readable, running as root")
+		}
+		err = ds.DoSync(ctx)
+		if !errors.Is(err, dsyncerr.ErrSourceUnreadable) {
+			t.Errorf("must be ErrSourceUnreadable, got %v", err)
+		}
+	})
+
+	t.Run("fail unreadable file is a partial transfer", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "a"), "hello")
+		locked := fmt.Sprintf("%s/%s", srcDir, "b")
+		writeFile(locked, "hello", 0000)
+
+		ds, err := New(ctx, srcDir, dstDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if readable, _ := ds.IsFileReadable(locked); readable {
+			t.Skip("file is still readable, running as root")
+		}
+		err = ds.DoSync(ctx)
+		if !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+			t.Errorf("must be ErrPartialTransfer, got %v", err)
+		}
+		if ds.GetSummary().New != 1 {
+			t.Errorf("readable file must still be copied")
+		}
+		failures := ds.GetFailures()
+		if len(failures) != 1 || failures[0].Path != locked {
+			t.Errorf("failure must be recorded for %s, got %v", locked, failures)
+		}
 	})
+}
 
+// cancelComparator cancels the sync while the entries are still being validated
+type cancelComparator struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelComparator) Changed(context.Context, FileState, FileState, Checksummer) (bool, error) {
+	c.cancel()
+	return true, nil
+}
+
+func TestDosyncCanceled(t *testing.T) {
+	srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+	if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+		t.Errorf("error")
+	}
+	defer func(s, d string) {
+		os.RemoveAll(s)
+		os.RemoveAll(d)
+	}(srcDir, dstDir)
+	writeFile(fmt.Sprintf("%s/%s", srcDir, "file"), "new")
+	writeFile(fmt.Sprintf("%s/%s", dstDir, "file"), "old")
+
+	// the walk is often over before the comparator cancels, the run must still report it
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		impl, err := New(ctx, srcDir, dstDir, WithComparator(cancelComparator{cancel}))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = impl.DoSync(ctx); !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Fatalf("sync must be canceled, got %v", err)
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+		impl, err = New(ctx, srcDir, dstDir, WithComparator(cancelComparator{cancel}))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if plan, err := impl.Plan(ctx); plan != nil || !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Fatalf("plan must be canceled, got %v", err)
+		}
+	}
 }
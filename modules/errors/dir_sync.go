@@ -1,8 +1,117 @@
 package dsyncerr
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
-	ErrNotDirectory          = errors.New("not a directory")
-	ErrSameSourceDestination = errors.New("source must not be the same with destination")
+	// ErrInvalidUsage is matched by the errors caused by a wrong configuration or input
+	ErrInvalidUsage = errors.New("invalid usage")
+	// ErrPartialTransfer is matched when some files could not be synced
+	ErrPartialTransfer = errors.New("partial transfer")
+	// ErrSourceUnreadable is matched when the source folder itself cannot be read
+	ErrSourceUnreadable = errors.New("source unreadable")
+	// ErrDestinationFull is matched when the destination has no space left
+	ErrDestinationFull = errors.New("destination full")
 )
+
+var (
+	ErrNotDirectory          = Wrap(ErrInvalidUsage, errors.New("not a directory"))
+	ErrSameSourceDestination = Wrap(ErrInvalidUsage, errors.New("source must not be the same with destination"))
+	ErrSyncCanceled          = errors.New("sync canceled")
+	ErrPlanMismatch          = Wrap(ErrInvalidUsage, errors.New("plan is made for different source or destination"))
+	ErrInvalidOperation      = Wrap(ErrInvalidUsage, errors.New("operation is outside of source or destination"))
+	ErrUnknownComparator     = Wrap(ErrInvalidUsage, errors.New("unknown comparator, must be one of quick, checksum, size or always"))
+	ErrInvalidPattern        = Wrap(ErrInvalidUsage, errors.New("invalid glob pattern"))
+	ErrUnknownHasher         = Wrap(ErrInvalidUsage, errors.New("unknown checksum algorithm, must be one of md5, sha256, sha512, blake2b or crc32c"))
+	ErrUnknownErrorPolicy    = Wrap(ErrInvalidUsage, errors.New("unknown error policy, must be fail-fast or continue"))
+	ErrUnknownSymlinkMode    = Wrap(ErrInvalidUsage, errors.New("unknown symlink mode, must be one of follow, copy, skip or safe"))
+	ErrDanglingSymlink       = errors.New("dangling symlink")
+)
+
+// kindError tags an error with the class it belongs to
+type kindError struct {
+	kind error
+	err  error
+}
+
+// Wrap will tag err with kind so errors.Is(err, kind) is true, the message stays the one of err
+func Wrap(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// FileError records the operation and path which failed during a sync,
+// a file error always means the transfer is partial
+type FileError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Err)
+}
+
+func (e *FileError) Is(target error) bool {
+	return target == ErrPartialTransfer
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError aggregates the errors of a run, errors.Is and errors.As match any of them
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors occurred:", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n\t* " + err.Error())
+	}
+	return b.String()
+}
+
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
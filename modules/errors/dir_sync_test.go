@@ -0,0 +1,74 @@
+package dsyncerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	t.Run("success match kind and cause", func(t *testing.T) {
+		cause := errors.New("no space left on device")
+		err := fmt.Errorf("copy: %w", Wrap(ErrDestinationFull, cause))
+		if !errors.Is(err, ErrDestinationFull) || !errors.Is(err, cause) {
+			t.Errorf("must match kind and cause, got %v", err)
+		}
+		if errors.Is(err, ErrSourceUnreadable) {
+			t.Errorf("must not match another kind")
+		}
+		if err.Error() != "copy: no space left on device" {
+			t.Errorf("message must be kept, got %s", err)
+		}
+	})
+
+	t.Run("success nil stays nil", func(t *testing.T) {
+		if Wrap(ErrDestinationFull, nil) != nil {
+			t.Errorf("must be nil")
+		}
+	})
+
+	t.Run("success usage errors", func(t *testing.T) {
+		for _, err := range []error{ErrNotDirectory, ErrSameSourceDestination, ErrUnknownComparator, ErrInvalidPattern, ErrUnknownHasher} {
+			if !errors.Is(err, ErrInvalidUsage) {
+				t.Errorf("%v must be an invalid usage", err)
+			}
+		}
+		if errors.Is(ErrSyncCanceled, ErrInvalidUsage) {
+			t.Errorf("canceled must not be an invalid usage")
+		}
+	})
+}
+
+func TestFileError(t *testing.T) {
+	cause := Wrap(ErrDestinationFull, errors.New("full"))
+	err := error(&FileError{Op: "copy", Path: "a", Err: cause})
+	if !errors.Is(err, ErrPartialTransfer) || !errors.Is(err, ErrDestinationFull) {
+		t.Errorf("must be a partial transfer caused by a full destination, got %v", err)
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	first := &FileError{Op: "copy", Path: "a", Err: Wrap(ErrDestinationFull, errors.New("full"))}
+	second := &FileError{Op: "skip", Path: "b", Err: errors.New("permission denied")}
+	err := error(&MultiError{Errors: []error{first, second}})
+
+	t.Run("success list every error", func(t *testing.T) {
+		want := "2 errors occurred:\n\t* copy a: full\n\t* skip b: permission denied"
+		if err.Error() != want {
+			t.Errorf("must be %q, got %q", want, err.Error())
+		}
+	})
+
+	t.Run("success match any error", func(t *testing.T) {
+		if !errors.Is(err, ErrPartialTransfer) || !errors.Is(err, ErrDestinationFull) {
+			t.Errorf("must match the errors it holds")
+		}
+		if errors.Is(err, ErrSyncCanceled) {
+			t.Errorf("must not match other errors")
+		}
+		var fileErr *FileError
+		if !errors.As(err, &fileErr) || fileErr != first {
+			t.Errorf("must be the first file error")
+		}
+	})
+}
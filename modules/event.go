@@ -0,0 +1,57 @@
+package dsync
+
+import (
+	"context"
+	"io"
+)
+
+// EventType is the kind of progress event emitted during a sync
+type EventType string
+
+const (
+	EventDiscovered EventType = "discovered" // a source file or symlink is found, Bytes is its size
+	EventCompared   EventType = "compared"   // an existing destination file is compared with its source
+	EventSkipped    EventType = "skipped"    // an entry is excluded, unreadable or unchanged
+	EventQueued     EventType = "queued"     // an operation is passed to the copiers, Bytes is its size
+	EventCopied     EventType = "copied"     // a file is copied or updated, Bytes is its size
+	EventDeleted    EventType = "deleted"    // a destination entry is deleted
+	EventFailed     EventType = "failed"     // an operation failed, Err holds the reason
+	EventBytes      EventType = "bytes"      // Bytes more bytes of a file are written
+)
+
+// Event describes the progress of a sync on a single path
+type Event struct {
+	Type  EventType
+	Op    OpKind
+	Path  string
+	Bytes int64
+	Err   error
+}
+
+// emit will send the event to the progress channel, the receiver must keep
+// reading otherwise the sync waits until the context is done
+func (ds *DirSync) emit(ctx context.Context, ev Event) {
+	if ds.progress == nil {
+		return
+	}
+	select {
+	case ds.progress <- ev:
+	case <-ctx.Done():
+	}
+}
+
+// progressWriter emits a bytes event for every write
+type progressWriter struct {
+	ctx  context.Context
+	ds   *DirSync
+	path string
+	w    io.Writer
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	if n > 0 {
+		pw.ds.emit(pw.ctx, Event{Type: EventBytes, Path: pw.path, Bytes: int64(n)})
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+package dsync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDosyncProgress(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success emit events", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "new"), randomString(100))
+		// directories are not discovered, only the files below them
+		if ensureDir(fmt.Sprintf("%s/%s", srcDir, "sub")) != nil || ensureDir(fmt.Sprintf("%s/%s", dstDir, "sub")) != nil {
+			t.Errorf("error")
+		}
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "sub/same"), "same")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "sub/same"), "same")
+
+		events := make(chan Event)
+		counts := make(map[EventType]int64)
+		collected := make(chan struct{})
+		go func() {
+			defer close(collected)
+			for ev := range events {
+				if ev.Type == EventBytes {
+					counts[ev.Type] += ev.Bytes
+					continue
+				}
+				counts[ev.Type]++
+			}
+		}()
+
+		ds, err := New(ctx, srcDir, dstDir, WithProgress(events), WithBufferSize(16))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+		close(events)
+		<-collected
+
+		if counts[EventDiscovered] != 2 || counts[EventCompared] != 1 || counts[EventSkipped] != 1 {
+			t.Errorf("unexpected events %v", counts)
+		}
+		if counts[EventQueued] != 1 || counts[EventCopied] != 1 || counts[EventBytes] != 100 {
+			t.Errorf("unexpected events %v", counts)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package dsync
+
+import (
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"path"
+	"strings"
+)
+
+// matchGlob will match a slash separated path against a pattern where *, ? and [...]
+// match inside a single path element and a ** element matches any number of elements
+func matchGlob(pattern, name string) bool {
+	return matchElements(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElements(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchElements(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], elems[0]); err != nil || !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// matchPattern will match a filter pattern against a path relative to the root. A pattern
+// without a slash matches the base name at any depth, otherwise it is anchored to the root.
+// A trailing slash only matches directories
+func matchPattern(pattern, rel string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		return matchGlob(pattern, path.Base(rel))
+	}
+	return matchGlob(strings.TrimPrefix(pattern, "/"), rel)
+}
+
+// validatePatterns will check the syntax of every element of the patterns
+func validatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		for _, elem := range strings.Split(strings.Trim(p, "/"), "/") {
+			if _, err := path.Match(elem, ""); err != nil {
+				return fmt.Errorf("%w: %s", dsyncerr.ErrInvalidPattern, p)
+			}
+		}
+	}
+	return nil
+}
+
+// isExcluded will check if a path relative to the root is filtered out by the ignore files
+// or the patterns, directories are only filtered by excludes so included files below them
+// are still reached
+func (ds *DirSync) isExcluded(rel string, isDir bool) bool {
+	if ds.ignores.isIgnored(rel, isDir, ds.Logger) {
+		return true
+	}
+	for _, p := range ds.Excludes {
+		if matchPattern(p, rel, isDir) {
+			return true
+		}
+	}
+	if len(ds.Includes) == 0 || isDir {
+		return false
+	}
+	for _, p := range ds.Includes {
+		if matchPattern(p, rel, isDir) {
+			return false
+		}
+	}
+	return true
+}
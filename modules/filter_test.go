@@ -0,0 +1,101 @@
+package dsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"os"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		{"*.go", "main.go", false, true},
+		{"*.go", "a/b/main.go", false, true},
+		{"*.go", "a/main.txt", false, false},
+		{"node_modules", "web/node_modules", true, true},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"/build", "a/build", true, false},
+		{"/build", "build", true, true},
+		{"a/*/c", "a/b/c", false, true},
+		{"a/*/c", "a/b/x/c", false, false},
+		{"a/**/c", "a/c", false, true},
+		{"a/**/c", "a/b/x/c", false, true},
+		{"**/*.log", "x/y/z.log", false, true},
+		{"**/*.log", "z.log", false, true},
+		{"a/**", "a/b/c", false, true},
+		{"a/**", "b/c", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.pattern, tt.rel), func(t *testing.T) {
+			if got := matchPattern(tt.pattern, tt.rel, tt.isDir); got != tt.want {
+				t.Errorf("match must be %t", tt.want)
+			}
+		})
+	}
+}
+
+func TestDosyncFilter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success include and exclude", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		for _, dir := range []string{"src", "node_modules", "node_modules/pkg"} {
+			if err := ensureDir(fmt.Sprintf("%s/%s", srcDir, dir)); err != nil {
+				t.Errorf("error")
+			}
+		}
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "src/main.go"), "package main")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "src/notes.txt"), "notes")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "node_modules/pkg/index.go"), "package pkg")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "kept.txt"), "not in source but excluded")
+
+		ds, err := New(ctx, srcDir, dstDir,
+			WithInclude("**/*.go"),
+			WithExclude("node_modules/"),
+			WithDelete(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+
+		if !ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "src/main.go")) {
+			t.Errorf("included file must be copied")
+		}
+		if ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "src/notes.txt")) {
+			t.Errorf("not included file must not be copied")
+		}
+		if ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "node_modules")) {
+			t.Errorf("excluded directory must not be copied")
+		}
+		if !ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "kept.txt")) {
+			t.Errorf("filtered file must not be deleted")
+		}
+	})
+
+	t.Run("fail invalid pattern", func(t *testing.T) {
+		_, err := New(ctx, sourceDir, destinationDir, WithExclude("[a-"))
+		if !errors.Is(err, dsyncerr.ErrInvalidPattern) {
+			t.Errorf("err must be %s", dsyncerr.ErrInvalidPattern)
+		}
+	})
+}
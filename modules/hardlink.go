@@ -0,0 +1,42 @@
+package dsync
+
+import (
+	"os"
+)
+
+// fileKey identifies a file whatever the path it is reached by
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// linkLeader will return the destination of the first path seen for the inode of a
+// file, an empty string when the file is that first path or is not hard linked
+func (ds *DirSync) linkLeader(path, dstPath string, info os.FileInfo) string {
+	key, ok := fileID(path, info)
+	if !ok {
+		return ""
+	}
+
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	if leader, found := ds.inodes[key]; found {
+		return leader
+	}
+	ds.inodes[key] = dstPath
+	return ""
+}
+
+// isLinked will check if the destination of a hard link operation already shares the
+// inode of its target
+func isLinked(op Operation) bool {
+	dstInfo, err := os.Lstat(op.Dst)
+	if err != nil {
+		return false
+	}
+	targetInfo, err := os.Lstat(op.Target)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(dstInfo, targetInfo)
+}
@@ -0,0 +1,123 @@
+package dsync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// sameFiles will check if every path shares the inode of the first one
+func sameFiles(t *testing.T, paths ...string) bool {
+	first, err := os.Stat(paths[0])
+	if err != nil {
+		t.Errorf("error %v", err)
+		return false
+	}
+	for _, p := range paths[1:] {
+		info, err := os.Stat(p)
+		if err != nil || !os.SameFile(first, info) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDosyncHardLinks(t *testing.T) {
+	ctx := context.Background()
+
+	srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+	if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil || ensureDir(fmt.Sprintf("%s/%s", srcDir, "sub")) != nil {
+		t.Errorf("error")
+	}
+	defer func(s, d string) {
+		os.RemoveAll(s)
+		os.RemoveAll(d)
+	}(srcDir, dstDir)
+
+	writeFile(fmt.Sprintf("%s/%s", srcDir, "a"), "hello")
+	for _, name := range []string{"b", "sub/c"} {
+		if err := os.Link(fmt.Sprintf("%s/%s", srcDir, "a"), fmt.Sprintf("%s/%s", srcDir, name)); err != nil {
+			t.Errorf("error %v", err)
+		}
+	}
+	dstFiles := []string{fmt.Sprintf("%s/%s", dstDir, "a"), fmt.Sprintf("%s/%s", dstDir, "b"), fmt.Sprintf("%s/%s", dstDir, "sub/c")}
+
+	run := func(t *testing.T, opts ...DSOptions) Summary {
+		ds, err := New(ctx, srcDir, dstDir, opts...)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil, got %v", err)
+		}
+		return ds.GetSummary()
+	}
+
+	t.Run("success copied once and linked", func(t *testing.T) {
+		summary := run(t, WithHardLinks(true))
+		if summary.New != 1 || summary.HardLinks != 2 {
+			t.Errorf("must copy 1 file and link 2, got %+v", summary)
+		}
+		if !sameFiles(t, dstFiles...) {
+			t.Errorf("destination files must share one inode")
+		}
+	})
+
+	t.Run("success links in place are kept", func(t *testing.T) {
+		summary := run(t, WithHardLinks(true))
+		if summary.New != 0 || summary.HardLinks != 0 || summary.Unchanged != 3 {
+			t.Errorf("must keep every file, got %+v", summary)
+		}
+	})
+
+	t.Run("success relinked when the target is replaced", func(t *testing.T) {
+		// written in place so every source path sees the new content
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "a"), "hello world")
+		summary := run(t, WithHardLinks(true))
+		if summary.Updated != 1 || summary.HardLinks != 2 {
+			t.Errorf("must update 1 file and link 2, got %+v", summary)
+		}
+		if !sameFiles(t, dstFiles...) {
+			t.Errorf("destination files must share one inode")
+		}
+	})
+
+	t.Run("success plan then sync on the same instance", func(t *testing.T) {
+		os.RemoveAll(dstDir)
+		if ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		ds, err := New(ctx, srcDir, dstDir, WithHardLinks(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if _, err = ds.Plan(ctx); err != nil {
+			t.Errorf("must be nil, got %v", err)
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil, got %v", err)
+		}
+		if summary := ds.GetSummary(); summary.New != 1 || summary.HardLinks != 2 {
+			t.Errorf("must copy 1 file and link 2, got %+v", summary)
+		}
+		if !sameFiles(t, dstFiles...) {
+			t.Errorf("destination files must share one inode")
+		}
+	})
+
+	t.Run("success copied per path by default", func(t *testing.T) {
+		os.RemoveAll(dstDir)
+		if ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		summary := run(t)
+		if summary.New != 3 || summary.HardLinks != 0 {
+			t.Errorf("must copy 3 files, got %+v", summary)
+		}
+		if sameFiles(t, dstFiles[:2]...) {
+			t.Errorf("destination files must not share an inode")
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package dsync
+
+import (
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"golang.org/x/crypto/blake2b"
+	"hash"
+	"hash/crc32"
+)
+
+// Hasher creates the hash used to checksum file contents
+type Hasher interface {
+	Name() string
+	New() hash.Hash
+}
+
+type hasher struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+func (h hasher) Name() string {
+	return h.name
+}
+
+func (h hasher) New() hash.Hash {
+	return h.newHash()
+}
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	MD5Hasher     Hasher = hasher{"md5", md5.New} //nolint:gosec
+	SHA256Hasher  Hasher = hasher{"sha256", sha256.New}
+	SHA512Hasher  Hasher = hasher{"sha512", sha512.New}
+	BLAKE2bHasher Hasher = hasher{"blake2b", func() hash.Hash {
+		h, _ := blake2b.New256(nil) // only fails for a key longer than 64 bytes
+		return h
+	}}
+	// CRC32CHasher is not cryptographic but the fastest, suited for bulk trees
+	CRC32CHasher Hasher = hasher{"crc32c", func() hash.Hash {
+		return crc32.New(castagnoliTable)
+	}}
+)
+
+// HasherByName will return the built-in hasher: md5, sha256, sha512, blake2b or crc32c
+func HasherByName(name string) (Hasher, error) {
+	for _, h := range []Hasher{MD5Hasher, SHA256Hasher, SHA512Hasher, BLAKE2bHasher, CRC32CHasher} {
+		if h.Name() == name {
+			return h, nil
+		}
+	}
+	return nil, dsyncerr.ErrUnknownHasher
+}
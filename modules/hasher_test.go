@@ -0,0 +1,69 @@
+package dsync
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"os"
+	"testing"
+)
+
+func TestHasherByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // sum of "hello"
+	}{
+		{"md5", "5d41402abc4b2a76b9719d911017c592"},
+		{"sha256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{"sha512", "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043"},
+		{"blake2b", "324dcf027dd4a30a932c441f365a25e86b173defa4b8e58948253471b81b72cf"},
+		{"crc32c", "9a71bb4c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := HasherByName(tt.name)
+			if err != nil {
+				t.Errorf("must be nil")
+			}
+			h := hasher.New()
+			h.Write([]byte("hello"))
+			if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+				t.Errorf("sum must be %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("fail unknown hasher", func(t *testing.T) {
+		_, err := HasherByName("md4")
+		if !errors.Is(err, dsyncerr.ErrUnknownHasher) {
+			t.Errorf("err must be %s", dsyncerr.ErrUnknownHasher)
+		}
+	})
+}
+
+func TestChecksumHasher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success checksum with sha256", func(t *testing.T) {
+		target := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		writeFile(target, "hello")
+		defer func(t string) {
+			os.RemoveAll(t)
+		}(target)
+
+		impl, err := New(ctx, sourceDir, destinationDir, WithHasher(SHA256Hasher))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		sum, err := impl.(*DirSync).Checksum(ctx, target)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if hex.EncodeToString(sum) != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+			t.Errorf("must be sha256 sum")
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package dsync
+
+import (
+	"bufio"
+	dsynclog "github.com/bondhan/sync/modules/logger"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	SyncIgnoreFile = ".syncignore"
+	GitIgnoreFile  = ".gitignore"
+)
+
+// ignoreRule is a single line of an ignore file, base is the directory of the
+// ignore file relative to the source root
+type ignoreRule struct {
+	pattern  string
+	base     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreLine will parse a line with gitignore syntax, ok is false for blank lines and comments
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	// trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, "\\!"), strings.HasPrefix(line, "\\#"):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// a slash at the beginning or in the middle anchors the pattern to the ignore file directory
+	rule.anchored = strings.Contains(line, "/")
+	rule.pattern = strings.TrimPrefix(line, "/")
+	if rule.pattern == "" {
+		return ignoreRule{}, false
+	}
+	return rule, true
+}
+
+// match will check if the rule matches a path relative to the source root
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	sub := rel
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		sub = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		return matchGlob(r.pattern, path.Base(sub))
+	}
+	return matchGlob(r.pattern, sub)
+}
+
+// ignoreMatcher loads the ignore files of the source tree on demand
+type ignoreMatcher struct {
+	root  string
+	names []string
+	lock  sync.Mutex
+	rules map[string][]ignoreRule
+}
+
+func newIgnoreMatcher(root string, names ...string) *ignoreMatcher {
+	return &ignoreMatcher{root: root, names: names, rules: make(map[string][]ignoreRule)}
+}
+
+// dirRules will return the rules declared in a directory relative to the root
+func (im *ignoreMatcher) dirRules(dir string, log dsynclog.Logger) []ignoreRule {
+	im.lock.Lock()
+	defer im.lock.Unlock()
+	if rules, ok := im.rules[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	for _, name := range im.names {
+		fileName := filepath.Join(im.root, filepath.FromSlash(dir), name)
+		file, err := os.Open(fileName)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warn("fail read ignore file", "path", fileName, "error", err)
+			}
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(scanner.Text(), dir); ok {
+				rules = append(rules, rule)
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			log.Warn("fail read ignore file", "path", fileName, "error", err)
+		}
+		if err = file.Close(); err != nil {
+			log.Warn("fail close ignore file", "path", fileName, "error", err)
+		}
+	}
+	im.rules[dir] = rules
+	return rules
+}
+
+// isIgnored will evaluate the rules of every ancestor directory from the root down,
+// the last matching rule wins and a negated rule re-includes the path
+func (im *ignoreMatcher) isIgnored(rel string, isDir bool, log dsynclog.Logger) bool {
+	dirs := []string{""}
+	elems := strings.Split(rel, "/")
+	for i := 1; i < len(elems); i++ {
+		dirs = append(dirs, strings.Join(elems[:i], "/"))
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		for _, rule := range im.dirRules(dir, log) {
+			if rule.match(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,107 @@
+package dsync
+
+import (
+	"context"
+	"fmt"
+	dsynclog "github.com/bondhan/sync/modules/logger"
+	"os"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	if ensureDir(root) != nil || ensureDir(fmt.Sprintf("%s/%s", root, "sub")) != nil {
+		t.Errorf("error")
+	}
+	defer func(r string) {
+		os.RemoveAll(r)
+	}(root)
+
+	writeFile(fmt.Sprintf("%s/%s", root, SyncIgnoreFile), `# comment
+*.log
+!keep.log
+/top.txt
+build/
+docs/**/*.pdf
+\#hash
+`)
+	writeFile(fmt.Sprintf("%s/%s", root, "sub/"+SyncIgnoreFile), `local.txt
+!debug.log
+`)
+	writeFile(fmt.Sprintf("%s/%s", root, GitIgnoreFile), "secret\n")
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"deep/dir/a.log", false, true},
+		{"keep.log", false, false},
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"docs/a/b/c.pdf", false, true},
+		{"docs/c.pdf", false, true},
+		{"other/c.pdf", false, false},
+		{"#hash", false, true},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"sub/debug.log", false, false},
+		{"secret", false, false},
+	}
+
+	log := dsynclog.Nop()
+	im := newIgnoreMatcher(root, SyncIgnoreFile)
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := im.isIgnored(tt.rel, tt.isDir, log); got != tt.want {
+				t.Errorf("ignored must be %t", tt.want)
+			}
+		})
+	}
+
+	t.Run("gitignore", func(t *testing.T) {
+		im := newIgnoreMatcher(root, SyncIgnoreFile, GitIgnoreFile)
+		if !im.isIgnored("secret", false, log) {
+			t.Errorf("must be ignored")
+		}
+	})
+}
+
+func TestDosyncIgnore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success skip ignored files", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil || ensureDir(fmt.Sprintf("%s/%s", srcDir, "tmp")) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, SyncIgnoreFile), "tmp/\n*.bak\n")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "tmp/cache"), "cache")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "data.bak"), "backup")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "data"), "data")
+
+		ds, err := New(ctx, srcDir, dstDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+
+		if !ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "data")) {
+			t.Errorf("file must be copied")
+		}
+		if ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "tmp")) || ds.IsFileExist(fmt.Sprintf("%s/%s", dstDir, "data.bak")) {
+			t.Errorf("ignored entries must not be copied")
+		}
+	})
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+
+package dsync
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID will return the device and inode of a file, ok is false unless the
+// inode is shared by several paths
+func fileID(_ string, info fs.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true //nolint:unconvert
+}
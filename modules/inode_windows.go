@@ -0,0 +1,27 @@
+package dsync
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID will return the volume and file index of a file, ok is false unless the
+// file has several names
+func fileID(path string, _ fs.FileInfo) (fileKey, bool) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return fileKey{}, false
+	}
+	h, err := syscall.CreateFile(name, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return fileKey{}, false
+	}
+	defer syscall.CloseHandle(h) //nolint:errcheck
+
+	var data syscall.ByHandleFileInformation
+	if err = syscall.GetFileInformationByHandle(h, &data); err != nil || data.NumberOfLinks < 2 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(data.VolumeSerialNumber), ino: uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow)}, true
+}
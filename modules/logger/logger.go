@@ -0,0 +1,187 @@
+package dsynclog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log record
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var (
+	ErrUnknownLevel  = errors.New("unknown log level, must be one of debug, info, warn or error")
+	ErrUnknownFormat = errors.New("unknown log format, must be text or json")
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// ParseLevel will return the level named debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if l.String() == strings.ToLower(name) {
+			return l, nil
+		}
+	}
+	return LevelDebug, ErrUnknownLevel
+}
+
+// Logger writes leveled records made of a message and key-value fields such as
+// "path", "op", "bytes" or "error"
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// Handler formats and writes a single record
+type Handler interface {
+	Handle(t time.Time, level Level, msg string, kv []interface{}) error
+}
+
+// leveled dispatches the records at or above a level to a handler
+type leveled struct {
+	level   Level
+	handler Handler
+}
+
+// New will create a logger writing the records at or above level with the handler
+func New(handler Handler, level Level) Logger {
+	return &leveled{level: level, handler: handler}
+}
+
+// NewText will create a logger writing logfmt-like lines to w
+func NewText(w io.Writer, level Level) Logger {
+	return New(&TextHandler{w: w}, level)
+}
+
+// NewJSON will create a logger writing a JSON object per line to w
+func NewJSON(w io.Writer, level Level) Logger {
+	return New(&JSONHandler{w: w}, level)
+}
+
+// Nop will create a logger which discards every record
+func Nop() Logger {
+	return New(nil, LevelError+1)
+}
+
+func (l *leveled) log(level Level, msg string, kv []interface{}) {
+	if level < l.level || l.handler == nil {
+		return
+	}
+	_ = l.handler.Handle(time.Now(), level, msg, kv) // nowhere to report a failing log writer
+}
+
+func (l *leveled) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+func (l *leveled) Info(msg string, kv ...interface{})  { l.log(LevelInfo, msg, kv) }
+func (l *leveled) Warn(msg string, kv ...interface{})  { l.log(LevelWarn, msg, kv) }
+func (l *leveled) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+// fields will pair the keys and values, a missing value is reported as such
+func fields(kv []interface{}) ([]string, []interface{}) {
+	keys := make([]string, 0, (len(kv)+1)/2)
+	values := make([]interface{}, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		keys = append(keys, fmt.Sprint(kv[i]))
+		if i+1 < len(kv) {
+			values = append(values, value(kv[i+1]))
+		} else {
+			values = append(values, "!MISSING")
+		}
+	}
+	return keys, values
+}
+
+// value will turn errors and stringers into text so they render in both handlers
+func value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
+
+// TextHandler writes records as `time level msg key=value ...` lines
+type TextHandler struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (h *TextHandler) Handle(t time.Time, level Level, msg string, kv []interface{}) error {
+	var b strings.Builder
+	b.WriteString("time=" + t.Format(time.RFC3339))
+	b.WriteString(" level=" + level.String())
+	b.WriteString(" msg=" + quote(msg))
+	keys, values := fields(kv)
+	for i, k := range keys {
+		b.WriteString(" " + k + "=" + quote(fmt.Sprint(values[i])))
+	}
+	b.WriteString("\n")
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// quote will quote a value only when it contains spaces, quotes or equal signs
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// JSONHandler writes records as one JSON object per line
+type JSONHandler struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (h *JSONHandler) Handle(t time.Time, level Level, msg string, kv []interface{}) error {
+	record := map[string]interface{}{
+		"time":  t.Format(time.RFC3339Nano),
+		"level": level.String(),
+		"msg":   msg,
+	}
+	keys, values := fields(kv)
+	for i, k := range keys {
+		record[k] = values[i]
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	_, err = h.w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,89 @@
+package dsynclog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	t.Run("success known levels", func(t *testing.T) {
+		for _, name := range []string{"debug", "info", "warn", "ERROR"} {
+			l, err := ParseLevel(name)
+			if err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+			if l.String() != strings.ToLower(name) {
+				t.Errorf("must be %s, got %s", name, l)
+			}
+		}
+	})
+
+	t.Run("fail unknown level", func(t *testing.T) {
+		if _, err := ParseLevel("trace"); !errors.Is(err, ErrUnknownLevel) {
+			t.Errorf("must be ErrUnknownLevel, got %v", err)
+		}
+	})
+}
+
+func TestTextLogger(t *testing.T) {
+	t.Run("success records at or above level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		log := NewText(out, LevelInfo)
+		log.Debug("hidden", "path", "a")
+		log.Info("file copied", "op", "copy", "path", "dir/a b", "bytes", 42)
+		log.Error("fail delete", "path", "c", "error", errors.New("denied"))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("must be 2 lines, got %q", out.String())
+		}
+		for _, want := range []string{"level=info", `msg="file copied"`, "op=copy", `path="dir/a b"`, "bytes=42"} {
+			if !strings.Contains(lines[0], want) {
+				t.Errorf("line %q must contain %q", lines[0], want)
+			}
+		}
+		for _, want := range []string{"level=error", "error=denied"} {
+			if !strings.Contains(lines[1], want) {
+				t.Errorf("line %q must contain %q", lines[1], want)
+			}
+		}
+	})
+
+	t.Run("success missing value", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		NewText(out, LevelDebug).Warn("odd", "path")
+		if !strings.Contains(out.String(), "path=!MISSING") {
+			t.Errorf("must report the missing value, got %q", out.String())
+		}
+	})
+}
+
+func TestJSONLogger(t *testing.T) {
+	t.Run("success one object per record", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		log := NewJSON(out, LevelDebug)
+		log.Warn("fail close file", "path", "a", "bytes", 7, "error", errors.New("boom"))
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+			t.Fatalf("must be nil, got %v", err)
+		}
+		want := map[string]interface{}{"level": "warn", "msg": "fail close file", "path": "a", "bytes": float64(7), "error": "boom"}
+		for k, v := range want {
+			if record[k] != v {
+				t.Errorf("%s must be %v, got %v", k, v, record[k])
+			}
+		}
+		if _, ok := record["time"]; !ok {
+			t.Error("time must be set")
+		}
+	})
+}
+
+func TestNop(t *testing.T) {
+	log := Nop()
+	log.Error("nothing", "path", "a") // must not panic without a handler
+}
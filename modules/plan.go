@@ -0,0 +1,95 @@
+package dsync
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// OpKind is the kind of change a sync run makes in the destination
+type OpKind string
+
+const (
+	OpMkdir  OpKind = "mkdir"
+	OpCopy   OpKind = "copy"
+	OpUpdate OpKind = "update"
+	OpDelete OpKind = "delete"
+	OpAttrs  OpKind = "attrs"
+	// OpSymlink creates a link to Target, only made when links are copied
+	OpSymlink OpKind = "symlink"
+	// OpHardlink links Dst to Target, the destination of another path of the same source inode
+	OpHardlink OpKind = "hardlink"
+	// opSkip only names the failure of an entry left out of the sync, it is never planned
+	opSkip OpKind = "skip"
+)
+
+// Operation is a single change in the destination, Src is empty for mkdir, attrs and delete.
+// Mode and times are the source attributes, applied only when they are preserved
+type Operation struct {
+	Kind       OpKind      `json:"kind"`
+	Src        string      `json:"src,omitempty"`
+	Dst        string      `json:"dst"`
+	Size       int64       `json:"size"`
+	Mode       fs.FileMode `json:"mode,omitempty"`
+	ModTime    time.Time   `json:"mtime"`
+	AccessTime time.Time   `json:"atime"`
+	Target     string      `json:"target,omitempty"`
+}
+
+// Plan is the list of operations a sync run would perform, it can be serialized
+// and applied later to the same source and destination roots
+type Plan struct {
+	SrcRoot    string      `json:"src_root"`
+	DstRoot    string      `json:"dst_root"`
+	Operations []Operation `json:"operations"`
+}
+
+// Count will return the number of operations of the given kind
+func (p *Plan) Count(kind OpKind) int {
+	total := 0
+	for _, op := range p.Operations {
+		if op.Kind == kind {
+			total++
+		}
+	}
+	return total
+}
+
+// Bytes will return the total size of operations of the given kind
+func (p *Plan) Bytes(kind OpKind) int64 {
+	total := int64(0)
+	for _, op := range p.Operations {
+		if op.Kind == kind {
+			total += op.Size
+		}
+	}
+	return total
+}
+
+// Print will write a human-readable listing of the plan followed by its totals
+func (p *Plan) Print(w io.Writer) error {
+	for _, op := range p.Operations {
+		var err error
+		switch op.Kind {
+		case OpCopy, OpUpdate:
+			_, err = fmt.Fprintf(w, "%-6s %s -> %s (%d bytes)\n", op.Kind, op.Src, op.Dst, op.Size)
+		case OpDelete:
+			_, err = fmt.Fprintf(w, "%-6s %s (%d bytes)\n", op.Kind, op.Dst, op.Size)
+		case OpSymlink, OpHardlink:
+			_, err = fmt.Fprintf(w, "%-6s %s -> %s\n", op.Kind, op.Dst, op.Target)
+		default:
+			_, err = fmt.Fprintf(w, "%-6s %s\n", op.Kind, op.Dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "mkdir: %d, attrs: %d, symlink: %d, hardlink: %d, copy: %d (%d bytes), update: %d (%d bytes), delete: %d (%d bytes)\n",
+		p.Count(OpMkdir), p.Count(OpAttrs), p.Count(OpSymlink), p.Count(OpHardlink),
+		p.Count(OpCopy), p.Bytes(OpCopy),
+		p.Count(OpUpdate), p.Bytes(OpUpdate),
+		p.Count(OpDelete), p.Bytes(OpDelete))
+	return err
+}
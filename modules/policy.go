@@ -0,0 +1,43 @@
+package dsync
+
+import (
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+)
+
+// ErrorPolicy decides when failed operations stop a sync, a MaxErrors of 0 never
+// stops it. Entries skipped because they cannot be read are reported but never
+// count toward the limit
+type ErrorPolicy struct {
+	MaxErrors int
+}
+
+var (
+	// FailFast stops the sync on the first failed operation
+	FailFast = ErrorPolicy{MaxErrors: 1}
+	// ContinueOnError keeps syncing the other files whatever fails
+	ContinueOnError = ErrorPolicy{}
+)
+
+// AbortAfter will return a policy stopping the sync once n operations failed
+func AbortAfter(n int) ErrorPolicy {
+	if n < 0 {
+		n = 0
+	}
+	return ErrorPolicy{MaxErrors: n}
+}
+
+// ErrorPolicyByName will return the policy named fail-fast or continue
+func ErrorPolicyByName(name string) (ErrorPolicy, error) {
+	switch name {
+	case "fail-fast":
+		return FailFast, nil
+	case "continue":
+		return ContinueOnError, nil
+	}
+	return ErrorPolicy{}, dsyncerr.ErrUnknownErrorPolicy
+}
+
+// stops will check if the number of failed operations reached the limit
+func (p ErrorPolicy) stops(failed int) bool {
+	return p.MaxErrors > 0 && failed >= p.MaxErrors
+}
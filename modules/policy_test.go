@@ -0,0 +1,226 @@
+package dsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestErrorPolicyByName(t *testing.T) {
+	t.Run("success known policies", func(t *testing.T) {
+		for name, want := range map[string]ErrorPolicy{"fail-fast": FailFast, "continue": ContinueOnError} {
+			got, err := ErrorPolicyByName(name)
+			if err != nil || got != want {
+				t.Errorf("must be %v, got %v %v", want, got, err)
+			}
+		}
+	})
+
+	t.Run("fail unknown policy", func(t *testing.T) {
+		if _, err := ErrorPolicyByName("retry"); !errors.Is(err, dsyncerr.ErrUnknownErrorPolicy) {
+			t.Errorf("must be ErrUnknownErrorPolicy, got %v", err)
+		}
+	})
+}
+
+// blockedTree will create files in source whose copy fails because a file takes the
+// place of their destination directory
+func blockedTree(t *testing.T, srcDir, dstDir string, blocked, good int) {
+	for i := 0; i < blocked; i++ {
+		sub := fmt.Sprintf("sub%d", i)
+		if ensureDir(fmt.Sprintf("%s/%s", srcDir, sub)) != nil {
+			t.Errorf("error")
+		}
+		writeFile(fmt.Sprintf("%s/%s/file", srcDir, sub), "hello")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, sub), "not a directory")
+	}
+	for i := 0; i < good; i++ {
+		writeFile(fmt.Sprintf("%s/%d", srcDir, i), "hello")
+	}
+}
+
+func TestDosyncErrorPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		policy   ErrorPolicy
+		failures int
+	}{
+		{"continue", ContinueOnError, 3},
+		{"abort after 2", AbortAfter(2), 2},
+		{"fail fast", FailFast, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+			dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+			if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+				t.Errorf("error")
+			}
+			defer func(s, d string) {
+				os.RemoveAll(s)
+				os.RemoveAll(d)
+			}(srcDir, dstDir)
+			blockedTree(t, srcDir, dstDir, 3, 20)
+
+			// a single copier makes the number of failures before an abort deterministic
+			ds, err := New(ctx, srcDir, dstDir, WithErrorPolicy(tt.policy), WithCopiers(1))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			err = ds.DoSync(ctx)
+			if !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+				t.Errorf("must be ErrPartialTransfer, got %v", err)
+			}
+			var multi *dsyncerr.MultiError
+			if !errors.As(err, &multi) || len(multi.Errors) != tt.failures {
+				t.Errorf("must list %d failures, got %v", tt.failures, err)
+			}
+			if len(ds.GetFailures()) != tt.failures {
+				t.Errorf("must record %d failures, got %d", tt.failures, len(ds.GetFailures()))
+			}
+			if tt.policy == ContinueOnError && ds.GetSummary().New != 20 {
+				t.Errorf("must copy every other file, got %d", ds.GetSummary().New)
+			}
+		})
+	}
+}
+
+func TestFinalizeDirsErrorPolicy(t *testing.T) {
+	ctx := context.Background()
+	// directories removed before their attributes are set
+	missing := []Operation{
+		{Kind: OpMkdir, Dst: fmt.Sprintf("%s/%s", destinationDir, randomString(5)), Mode: os.ModeDir | 0700},
+		{Kind: OpMkdir, Dst: fmt.Sprintf("%s/%s", destinationDir, randomString(5)), Mode: os.ModeDir | 0700},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		policy ErrorPolicy
+		failed int
+	}{
+		{"success every directory attempted with continue", ContinueOnError, 2},
+		{"fail first directory stops with fail fast", FailFast, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			impl, err := New(ctx, sourceDir, destinationDir, WithPreservePerms(true), WithErrorPolicy(tt.policy))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			ds := impl.(*DirSync)
+			for _, op := range missing {
+				ds.deferDir(op)
+			}
+
+			err = ds.finalizeDirs(ctx)
+			if tt.policy == FailFast && !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+				t.Errorf("err must be %s, got %v", dsyncerr.ErrPartialTransfer, err)
+			}
+			if tt.policy == ContinueOnError && err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+			if len(ds.GetFailures()) != tt.failed {
+				t.Errorf("must record %d failures, got %d", tt.failed, len(ds.GetFailures()))
+			}
+			if errPartial := ds.partialErr(); !errors.Is(errPartial, dsyncerr.ErrPartialTransfer) {
+				t.Errorf("err must be %s, got %v", dsyncerr.ErrPartialTransfer, errPartial)
+			}
+		})
+	}
+}
+
+func TestDosyncEntryFailure(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		policy ErrorPolicy
+	}{
+		{"success other files synced with continue", ContinueOnError},
+		{"fail partial transfer with fail fast", FailFast},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+			dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+			if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+				t.Errorf("error")
+			}
+			defer func(s, d string) {
+				os.RemoveAll(s)
+				os.RemoveAll(d)
+			}(srcDir, dstDir)
+
+			// a socket cannot be opened, only this entry must fail
+			sock := fmt.Sprintf("%s/%s", srcDir, "sock")
+			listener, err := net.Listen("unix", sock)
+			if err != nil {
+				t.Skipf("unix socket not supported: %v", err)
+			}
+			defer listener.Close()
+			writeFile(fmt.Sprintf("%s/%s", srcDir, "file"), "hello")
+
+			ds, err := New(ctx, srcDir, dstDir, WithErrorPolicy(tt.policy))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			err = ds.DoSync(ctx)
+			if !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+				t.Errorf("must be ErrPartialTransfer, got %v", err)
+			}
+			failures := ds.GetFailures()
+			if len(failures) != 1 || failures[0].Path != sock {
+				t.Errorf("failure must be recorded for %s, got %v", sock, failures)
+			}
+			if tt.policy == ContinueOnError && ds.GetSummary().New != 1 {
+				t.Errorf("must copy the other file, got %d", ds.GetSummary().New)
+			}
+		})
+	}
+}
+
+func TestDosyncRunsOnSameInstance(t *testing.T) {
+	ctx := context.Background()
+
+	srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+	if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+		t.Errorf("error")
+	}
+	defer func(s, d string) {
+		os.RemoveAll(s)
+		os.RemoveAll(d)
+	}(srcDir, dstDir)
+	writeFile(fmt.Sprintf("%s/%s", srcDir, "file"), "hello")
+	dangle := fmt.Sprintf("%s/%s", srcDir, "dangle")
+	if err := os.Symlink(fmt.Sprintf("%s/%s", srcDir, "missing"), dangle); err != nil {
+		t.Fatalf("error %v", err)
+	}
+
+	ds, err := New(ctx, srcDir, dstDir)
+	if err != nil {
+		t.Errorf("fail test")
+	}
+	if err = ds.DoSync(ctx); !errors.Is(err, dsyncerr.ErrPartialTransfer) {
+		t.Errorf("must be ErrPartialTransfer, got %v", err)
+	}
+
+	// the second run must not report what the first one counted or failed
+	if err = os.Remove(dangle); err != nil {
+		t.Fatalf("error %v", err)
+	}
+	if err = ds.DoSync(ctx); err != nil {
+		t.Errorf("must be nil, got %v", err)
+	}
+	summary := ds.GetSummary()
+	if summary.Failed != 0 || summary.Skipped != 0 || summary.Scanned != 1 || summary.New != 0 || summary.Unchanged != 1 {
+		t.Errorf("summary must only count the second run, got %+v", summary)
+	}
+	if report := ds.GetReport(); len(report.Errors) != 0 || report.Error != "" {
+		t.Errorf("report must have no error, got %+v", report)
+	}
+}
@@ -0,0 +1,151 @@
+package dsyncprogress
+
+import (
+	"fmt"
+	dsync "github.com/bondhan/sync/modules"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// TTYInterval is how often the progress line is redrawn on a terminal
+	TTYInterval = 200 * time.Millisecond
+	// LogInterval is how often a progress line is logged when the output is not a terminal
+	LogInterval = 10 * time.Second
+)
+
+// Bar renders the progress of a sync from its events, on a terminal as a single live line,
+// otherwise as periodic log lines
+type Bar struct {
+	out      io.Writer
+	isTTY    bool
+	interval time.Duration
+	start    time.Time
+
+	lock        sync.Mutex
+	scanned     int64
+	copied      int64
+	queuedBytes int64
+	copiedBytes int64
+	current     string
+	rate        float64
+	lastBytes   int64
+	lastTime    time.Time
+}
+
+// IsTerminal will check if the file is a character device such as a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// New will create a progress bar writing to out
+func New(out io.Writer, isTTY bool) *Bar {
+	interval := LogInterval
+	if isTTY {
+		interval = TTYInterval
+	}
+	now := time.Now()
+	return &Bar{out: out, isTTY: isTTY, interval: interval, start: now, lastTime: now}
+}
+
+// Run will consume the events and render the progress until the channel is closed
+func (b *Bar) Run(events <-chan dsync.Event) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				b.render(time.Now(), true)
+				return
+			}
+			b.update(ev)
+		case now := <-ticker.C:
+			b.render(now, false)
+		}
+	}
+}
+
+func (b *Bar) update(ev dsync.Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch ev.Type {
+	case dsync.EventDiscovered:
+		b.scanned++
+	case dsync.EventQueued:
+		b.queuedBytes += ev.Bytes
+	case dsync.EventBytes:
+		b.copiedBytes += ev.Bytes
+		b.current = ev.Path
+	case dsync.EventCopied:
+		b.copied++
+	}
+}
+
+// Line will return the current progress line
+func (b *Bar) Line(now time.Time) string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.line(now)
+}
+
+func (b *Bar) line(now time.Time) string {
+	// smooth the throughput measured since the previous line
+	if elapsed := now.Sub(b.lastTime).Seconds(); elapsed > 0 {
+		instant := float64(b.copiedBytes-b.lastBytes) / elapsed
+		if b.lastBytes == 0 && b.rate == 0 {
+			b.rate = instant
+		} else {
+			b.rate = 0.7*b.rate + 0.3*instant
+		}
+		b.lastBytes, b.lastTime = b.copiedBytes, now
+	}
+
+	eta := "-"
+	if remaining := b.queuedBytes - b.copiedBytes; remaining > 0 && b.rate > 0 {
+		eta = time.Duration(float64(remaining) / b.rate * float64(time.Second)).Round(time.Second).String()
+	} else if remaining <= 0 {
+		eta = "0s"
+	}
+
+	return fmt.Sprintf("scanned %d, copied %d, %s/%s, %s/s, ETA %s, elapsed %s %s",
+		b.scanned, b.copied,
+		HumanBytes(b.copiedBytes), HumanBytes(b.queuedBytes), HumanBytes(int64(b.rate)),
+		eta, now.Sub(b.start).Round(time.Second), b.current)
+}
+
+func (b *Bar) render(now time.Time, final bool) {
+	line := b.Line(now)
+	if b.isTTY {
+		// redraw the line in place and clear what is left of the previous one
+		if final {
+			fmt.Fprintf(b.out, "\r%s\x1b[K\n", line)
+			return
+		}
+		fmt.Fprintf(b.out, "\r%s\x1b[K", line)
+		return
+	}
+	fmt.Fprintf(b.out, "%s %s\n", now.Format(time.RFC3339), line)
+}
+
+// HumanBytes will format a number of bytes with a binary unit
+func HumanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
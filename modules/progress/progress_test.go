@@ -0,0 +1,71 @@
+package dsyncprogress
+
+import (
+	"bytes"
+	dsync "github.com/bondhan/sync/modules"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{1536, "1.5KiB"},
+		{5 * 1024 * 1024, "5.0MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0GiB"},
+	}
+	for _, tt := range tests {
+		if got := HumanBytes(tt.n); got != tt.want {
+			t.Errorf("must be %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	t.Run("success line from events", func(t *testing.T) {
+		bar := New(&bytes.Buffer{}, false)
+		bar.update(dsync.Event{Type: dsync.EventDiscovered, Path: "a", Bytes: 2048})
+		bar.update(dsync.Event{Type: dsync.EventDiscovered, Path: "b", Bytes: 2048})
+		bar.update(dsync.Event{Type: dsync.EventQueued, Path: "a", Bytes: 2048})
+		bar.update(dsync.Event{Type: dsync.EventBytes, Path: "a", Bytes: 1024})
+
+		line := bar.Line(bar.start.Add(time.Second))
+		for _, want := range []string{"scanned 2", "copied 0", "1.0KiB/2.0KiB", "1.0KiB/s", "ETA 1s", " a"} {
+			if !strings.Contains(line, want) {
+				t.Errorf("line %q must contain %q", line, want)
+			}
+		}
+	})
+
+	t.Run("success log lines when not a terminal", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		bar := New(out, false)
+		events := make(chan dsync.Event, 2)
+		events <- dsync.Event{Type: dsync.EventDiscovered, Path: "a", Bytes: 1}
+		events <- dsync.Event{Type: dsync.EventCopied, Path: "a", Bytes: 1}
+		close(events)
+
+		bar.Run(events)
+		if strings.Contains(out.String(), "\r") || !strings.Contains(out.String(), "copied 1") {
+			t.Errorf("unexpected output %q", out.String())
+		}
+	})
+
+	t.Run("success redraw in place on a terminal", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		bar := New(out, true)
+		events := make(chan dsync.Event)
+		close(events)
+
+		bar.Run(events)
+		if !strings.HasPrefix(out.String(), "\r") || !strings.HasSuffix(out.String(), "\n") {
+			t.Errorf("unexpected output %q", out.String())
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package dsync
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// ReportError is the failure of a single file in a report
+type ReportError struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Report is the machine readable outcome of a DoSync run, Error is the error which
+// stopped the run if any, a run with Failed files but no Error is partial
+type Report struct {
+	SrcRoot   string    `json:"src_root"`
+	DstRoot   string    `json:"dst_root"`
+	DryRun    bool      `json:"dry_run"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Duration  float64   `json:"duration_seconds"`
+	Summary
+	Errors []ReportError `json:"errors"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// GetReport will return the report of the last DoSync run
+func (ds *DirSync) GetReport() *Report {
+	summary := ds.GetSummary()
+
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	report := &Report{
+		SrcRoot:   ds.AbsSrcRoot,
+		DstRoot:   ds.AbsDstRoot,
+		DryRun:    ds.DryRun,
+		StartedAt: ds.startedAt,
+		EndedAt:   ds.endedAt,
+		Duration:  ds.endedAt.Sub(ds.startedAt).Seconds(),
+		Summary:   summary,
+		Errors:    make([]ReportError, 0, len(ds.Failures)),
+	}
+	for _, f := range ds.Failures {
+		report.Errors = append(report.Errors, ReportError{Op: f.Op, Path: f.Path, Error: f.Err.Error()})
+	}
+	if ds.runErr != nil {
+		report.Error = ds.runErr.Error()
+	}
+	return report
+}
+
+// WriteJSON will write the report as an indented JSON document
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
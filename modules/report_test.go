@@ -0,0 +1,86 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestGetReport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success counts and bytes", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "a"), "hello")
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "b.tmp"), "skipped")
+
+		ds, err := New(ctx, srcDir, dstDir, WithExclude("*.tmp"))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil")
+		}
+
+		report := ds.GetReport()
+		if report.Scanned != 1 || report.New != 1 || report.Skipped != 1 || report.Bytes != 5 || report.Failed != 0 {
+			t.Errorf("unexpected summary %+v", report.Summary)
+		}
+		if report.StartedAt.IsZero() || report.EndedAt.Before(report.StartedAt) || report.Error != "" {
+			t.Errorf("unexpected run %+v", report)
+		}
+
+		out := &bytes.Buffer{}
+		if err = report.WriteJSON(out); err != nil {
+			t.Errorf("must be nil")
+		}
+		var doc map[string]interface{}
+		if err = json.Unmarshal(out.Bytes(), &doc); err != nil {
+			t.Errorf("must be nil")
+		}
+		if doc["copied"] != float64(1) || doc["bytes"] != float64(5) {
+			t.Errorf("unexpected json %s", out.String())
+		}
+	})
+
+	t.Run("success failures are reported", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil || ensureDir(fmt.Sprintf("%s/%s", srcDir, "sub")) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+
+		target := fmt.Sprintf("%s/%s", srcDir, "sub/file")
+		writeFile(target, "hello")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, "sub"), "not a directory")
+
+		ds, err := New(ctx, srcDir, dstDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err == nil {
+			t.Errorf("must not be nil")
+		}
+
+		report := ds.GetReport()
+		if report.Failed != 1 || len(report.Errors) != 1 || report.Errors[0].Path != target || report.Error == "" {
+			t.Errorf("unexpected report %+v", report)
+		}
+	})
+}
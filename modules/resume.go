@@ -0,0 +1,179 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// PartialMarker ends the name of a file whose copy was interrupted while resume is on,
+	// the next run appends to it instead of starting over
+	PartialMarker = ".sync-partial"
+	// partialRecordExt ends the name of the sidecar describing a partial file
+	partialRecordExt = ".json"
+)
+
+var errStalePartial = errors.New("partial file does not match the source")
+
+// partialRecord tells which source a partial file was copied from and how far
+type partialRecord struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Written int64     `json:"written"`
+}
+
+// partialNames will return the names of the partial file of dst and of its sidecar
+func partialNames(dst string) (string, string) {
+	name := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+PartialMarker)
+	return name, name + partialRecordExt
+}
+
+// partialTarget will return the name of the file a partial file or sidecar is copied to
+func partialTarget(name string) (string, bool) {
+	if !strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, partialRecordExt)
+	if !strings.HasSuffix(name, PartialMarker) || len(name) <= len(PartialMarker)+1 {
+		return "", false
+	}
+	return strings.TrimSuffix(name[1:], PartialMarker), true
+}
+
+// openPartial will open the partial file of op.Dst positioned after the bytes which can be
+// kept, a missing or stale record or a prefix differing from src means starting over
+func (ds *DirSync) openPartial(ctx context.Context, src *os.File, op Operation) (*os.File, int64, error) {
+	name, recordName := partialNames(op.Dst)
+	offset, err := ds.resumeOffset(ctx, src, name, recordName, op)
+	if err != nil {
+		ds.Logger.Debug("partial file not resumed", "path", name, "error", err)
+		offset = 0
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = f.Truncate(offset); err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err == nil {
+		_, err = src.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		if errClose := f.Close(); errClose != nil {
+			ds.Logger.Warn("fail close file", "path", name, "error", errClose)
+		}
+		return nil, 0, err
+	}
+	if offset > 0 {
+		ds.Logger.Info("resuming partial copy", "path", op.Dst, "bytes", offset)
+		ds.emit(ctx, Event{Type: EventBytes, Path: op.Src, Bytes: offset})
+	}
+	return f, offset, nil
+}
+
+// resumeOffset will return the number of bytes of the partial file matching the record and src
+func (ds *DirSync) resumeOffset(ctx context.Context, src *os.File, name, recordName string, op Operation) (int64, error) {
+	data, err := os.ReadFile(recordName)
+	if err != nil {
+		return 0, err
+	}
+	var record partialRecord
+	if err = json.Unmarshal(data, &record); err != nil {
+		return 0, err
+	}
+	if record.Size != op.Size || !record.ModTime.Equal(op.ModTime) || record.Written > record.Size {
+		return 0, errStalePartial
+	}
+
+	partial, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func(f *os.File) {
+		if errClose := f.Close(); errClose != nil {
+			ds.Logger.Warn("fail close file", "path", name, "error", errClose)
+		}
+	}(partial)
+
+	same, err := ds.samePrefix(ctx, src, partial, record.Written)
+	if err != nil {
+		return 0, err
+	}
+	if !same {
+		return 0, errStalePartial
+	}
+	return record.Written, nil
+}
+
+// samePrefix will compare the first n bytes of a and b through a single pooled buffer
+func (ds *DirSync) samePrefix(ctx context.Context, a, b io.ReaderAt, n int64) (bool, error) {
+	buf, err := ds.buffers.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer ds.buffers.put(buf)
+
+	pair := *buf
+	if len(pair) < 2 {
+		pair = make([]byte, 2) // a single byte buffer cannot hold both sides
+	}
+	half := len(pair) / 2
+	left, right := pair[:half], pair[half:2*half]
+	for offset := int64(0); offset < n; offset += int64(half) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		size := int64(half)
+		if n-offset < size {
+			size = n - offset
+		}
+		if _, err = a.ReadAt(left[:size], offset); err != nil {
+			return false, err
+		}
+		if _, err = b.ReadAt(right[:size], offset); err != nil {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+		if !bytes.Equal(left[:size], right[:size]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// keepPartial will record how much of op.Src the partial file holds so the next run resumes it
+func (ds *DirSync) keepPartial(f *os.File, op Operation) {
+	_, recordName := partialNames(op.Dst)
+	written, err := f.Seek(0, io.SeekCurrent)
+	var info os.FileInfo
+	if err == nil {
+		// a sparse copy seeks over zeros, only the bytes before the end of the file are kept
+		info, err = f.Stat()
+	}
+	if err == nil {
+		if info.Size() < written {
+			written = info.Size()
+		}
+		var data []byte
+		data, err = json.Marshal(partialRecord{Size: op.Size, ModTime: op.ModTime, Written: written})
+		if err == nil {
+			err = os.WriteFile(recordName, data, 0600)
+		}
+	}
+	if err != nil {
+		ds.Logger.Warn("fail record partial file, it will be copied again", "path", recordName, "error", err)
+		return
+	}
+	ds.Logger.Info("partial file kept", "path", op.Dst, "bytes", written)
+}
@@ -0,0 +1,196 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	dsynclog "github.com/bondhan/sync/modules/logger"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartialTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		ok     bool
+	}{
+		{".disk.img.sync-partial", "disk.img", true},
+		{".disk.img.sync-partial.json", "disk.img", true},
+		{"disk.img.sync-partial", "", false},
+		{".sync-partial", "", false},
+		{".disk.img.sync-tmp-123", "", false},
+	}
+	for _, tt := range tests {
+		if target, ok := partialTarget(tt.name); target != tt.target || ok != tt.ok {
+			t.Errorf("must be %q %v for %s, got %q %v", tt.target, tt.ok, tt.name, target, ok)
+		}
+	}
+}
+
+func TestDosyncResume(t *testing.T) {
+	ctx := context.Background()
+	content := strings.Repeat("0123456789", 10000)
+
+	setup := func(t *testing.T) (string, string) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "big.bin"), content)
+		return srcDir, dstDir
+	}
+	// partial will leave a partial file of n bytes of data along with its record
+	partial := func(t *testing.T, srcDir, dstDir, data string, n int64) {
+		info, err := os.Stat(fmt.Sprintf("%s/%s", srcDir, "big.bin"))
+		if err != nil {
+			t.Fatalf("error %v", err)
+		}
+		name, recordName := partialNames(fmt.Sprintf("%s/%s", dstDir, "big.bin"))
+		writeFile(name, data)
+		record, _ := json.Marshal(partialRecord{Size: info.Size(), ModTime: info.ModTime(), Written: n})
+		writeFile(recordName, string(record))
+	}
+
+	for _, tt := range []struct {
+		name    string
+		data    string
+		written int64
+		resumed bool
+	}{
+		{"success prefix appended to", content[:40000], 40000, true},
+		{"success extra bytes after the record dropped", content[:40000] + "garbage", 40000, true},
+		{"success prefix differing copied again", "x" + content[1:40000], 40000, false},
+		{"success record beyond the file copied again", content[:100], 40000, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir, dstDir := setup(t)
+			defer os.RemoveAll(srcDir)
+			defer os.RemoveAll(dstDir)
+			partial(t, srcDir, dstDir, tt.data, tt.written)
+
+			out := &bytes.Buffer{}
+			ds, err := New(ctx, srcDir, dstDir, WithResume(true), WithLogger(dsynclog.NewText(out, dsynclog.LevelDebug)))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			if err = ds.DoSync(ctx); err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+
+			got, _ := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "big.bin"))
+			if string(got) != content {
+				t.Errorf("destination must be equal to source")
+			}
+			if strings.Contains(out.String(), "resuming partial copy") != tt.resumed {
+				t.Errorf("resumed must be %v, log %s", tt.resumed, out.String())
+			}
+			entries, _ := os.ReadDir(dstDir)
+			if len(entries) != 1 {
+				t.Errorf("partial file and record must be removed, got %d entries", len(entries))
+			}
+		})
+	}
+
+	t.Run("success partial kept when canceled", func(t *testing.T) {
+		srcDir, dstDir := setup(t)
+		defer os.RemoveAll(srcDir)
+		defer os.RemoveAll(dstDir)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		impl, err := New(ctx, srcDir, dstDir, WithResume(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		ds := impl.(*DirSync)
+		dst := fmt.Sprintf("%s/%s", dstDir, "big.bin")
+		err = ds.copyFile(cctx, Operation{Kind: OpCopy, Src: fmt.Sprintf("%s/%s", srcDir, "big.bin"), Dst: dst,
+			Size: int64(len(content)), ModTime: time.Now()})
+		if !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("err must be %s", dsyncerr.ErrSyncCanceled)
+		}
+		name, recordName := partialNames(dst)
+		if _, err = os.Stat(name); err != nil {
+			t.Errorf("partial file must be kept")
+		}
+		if _, err = os.Stat(recordName); err != nil {
+			t.Errorf("partial record must be kept")
+		}
+		if _, err = os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("destination must not be created")
+		}
+	})
+
+	t.Run("success delete keeps partial of existing source only", func(t *testing.T) {
+		srcDir, dstDir := setup(t)
+		defer os.RemoveAll(srcDir)
+		defer os.RemoveAll(dstDir)
+		writeFile(fmt.Sprintf("%s/%s", dstDir, ".gone.bin"+PartialMarker), "old")
+		writeFile(fmt.Sprintf("%s/%s", dstDir, ".big.bin"+PartialMarker), "old")
+
+		impl, err := New(ctx, srcDir, dstDir, WithDelete(true), WithResume(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		ds := impl.(*DirSync)
+		deleted := map[string]bool{}
+		err = ds.deleteExtraneous(ctx, make(chan struct{}), func(op Operation) error {
+			deleted[op.Dst] = true
+			return nil
+		})
+		if err != nil {
+			t.Errorf("must be nil, got %v", err)
+		}
+		if !deleted[fmt.Sprintf("%s/%s", dstDir, ".gone.bin"+PartialMarker)] {
+			t.Errorf("partial file of a removed source must be deleted")
+		}
+		if deleted[fmt.Sprintf("%s/%s", dstDir, ".big.bin"+PartialMarker)] {
+			t.Errorf("partial file of an existing source must be kept")
+		}
+	})
+
+	t.Run("success partial files removed when resume is off", func(t *testing.T) {
+		srcDir, dstDir := setup(t)
+		defer os.RemoveAll(srcDir)
+		defer os.RemoveAll(dstDir)
+		partial(t, srcDir, dstDir, content[:100], 100)
+
+		ds, err := New(ctx, srcDir, dstDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil, got %v", err)
+		}
+		entries, _ := os.ReadDir(dstDir)
+		if len(entries) != 1 {
+			t.Errorf("partial file and record must be removed, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("success prefix verified with single byte buffers", func(t *testing.T) {
+		srcDir, dstDir := setup(t)
+		defer os.RemoveAll(srcDir)
+		defer os.RemoveAll(dstDir)
+		partial(t, srcDir, dstDir, content[:100], 100)
+
+		ds, err := New(ctx, srcDir, dstDir, WithResume(true), WithBufferSize(1), WithMaxMemory(1))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		if err = ds.DoSync(ctx); err != nil {
+			t.Errorf("must be nil, got %v", err)
+		}
+		got, _ := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "big.bin"))
+		if string(got) != content {
+			t.Errorf("destination must be equal to source")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package dsync
+
+import (
+	"context"
+	"errors"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"io/fs"
+	"math/rand"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultRetryBackoff    = 200 * time.Millisecond
+	DefaultRetryMaxBackoff = 10 * time.Second
+	DefaultRetryJitter     = 0.2
+)
+
+// RetryPolicy tells how many times a file operation failing with a transient error is
+// attempted, the wait doubles after each attempt from Backoff up to MaxBackoff and is
+// randomized by plus or minus Jitter of itself
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Jitter     float64
+}
+
+// NoRetry attempts every operation once
+var NoRetry = RetryPolicy{Attempts: 1}
+
+// retryableErrors are transient, mostly seen on network file systems
+var retryableErrors = []error{
+	syscall.EIO,
+	syscall.EAGAIN,
+	syscall.ETIMEDOUT,
+	syscall.ESTALE,
+}
+
+// IsRetryable will check if err is a transient I/O error worth another attempt
+func IsRetryable(err error) bool {
+	for _, target := range retryableErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay will return the wait before the attempt following the given one, starting at 1
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// retry will run fn until it succeeds, fails with a permanent error or the attempts
+// of the retry policy are exhausted, the last error is returned
+func (ds *DirSync) retry(ctx context.Context, path string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsRetryable(err) || attempt >= ds.Retry.Attempts {
+			return err
+		}
+
+		wait := ds.Retry.delay(attempt)
+		ds.Logger.Warn("transient error, will retry", "path", path, "attempt", attempt, "wait", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return dsyncerr.ErrSyncCanceled
+		}
+	}
+}
+
+// stat will stat path, retrying transient errors
+func (ds *DirSync) stat(ctx context.Context, path string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := ds.retry(ctx, path, func() error {
+		var err error
+		info, err = os.Stat(path)
+		return err
+	})
+	return info, err
+}
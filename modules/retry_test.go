@@ -0,0 +1,119 @@
+package dsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "read", Path: "a", Err: syscall.EIO}, true},
+		{fmt.Errorf("copy: %w", syscall.EAGAIN), true},
+		{syscall.ETIMEDOUT, true},
+		{syscall.ESTALE, true},
+		{&fs.PathError{Op: "open", Path: "a", Err: syscall.ENOENT}, false},
+		{fs.ErrPermission, false},
+		{errors.New("other"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%v must be %v", tt.err, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.delay(i + 1); got != w {
+			t.Errorf("attempt %d must wait %s, got %s", i+1, w, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.delay(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Errorf("must be within the jitter, got %s", got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	impl, err := New(ctx, sourceDir, destinationDir, WithRetry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}))
+	if err != nil {
+		t.Errorf("fail test")
+	}
+	ds := impl.(*DirSync)
+
+	t.Run("success after transient errors", func(t *testing.T) {
+		calls := 0
+		err := ds.retry(ctx, "a", func() error {
+			calls++
+			if calls < 3 {
+				return syscall.EIO
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("must be nil after 3 calls, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("fail attempts exhausted", func(t *testing.T) {
+		calls := 0
+		err := ds.retry(ctx, "a", func() error {
+			calls++
+			return syscall.ETIMEDOUT
+		})
+		if !errors.Is(err, syscall.ETIMEDOUT) || calls != 3 {
+			t.Errorf("must be ETIMEDOUT after 3 calls, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("fail permanent error is not retried", func(t *testing.T) {
+		calls := 0
+		err := ds.retry(ctx, "a", func() error {
+			calls++
+			return fs.ErrPermission
+		})
+		if !errors.Is(err, fs.ErrPermission) || calls != 1 {
+			t.Errorf("must be ErrPermission after 1 call, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("fail canceled while waiting", func(t *testing.T) {
+		ds.Retry.Backoff = time.Hour
+		defer func() { ds.Retry.Backoff = time.Millisecond }()
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := ds.retry(cctx, "a", func() error {
+			return syscall.EAGAIN
+		})
+		if !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("must be ErrSyncCanceled, got %v", err)
+		}
+	})
+
+	t.Run("success no retry by default", func(t *testing.T) {
+		impl, _ := New(ctx, sourceDir, destinationDir)
+		calls := 0
+		_ = impl.(*DirSync).retry(ctx, "a", func() error {
+			calls++
+			return syscall.EIO
+		})
+		if calls != 1 {
+			t.Errorf("must be called once, got %d", calls)
+		}
+	})
+}
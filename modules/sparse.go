@@ -0,0 +1,84 @@
+package dsync
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// sparseBlockSize is the granularity of the zero block detection, the usual file system block
+const sparseBlockSize = 4096
+
+// sparseWriter seeks over the blocks full of zeros instead of writing them, leaving holes
+type sparseWriter struct {
+	f *os.File
+}
+
+func (sw *sparseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := sparseBlockSize
+		if n > len(p) {
+			n = len(p)
+		}
+		if isZero(p[:n]) {
+			if _, err := sw.f.Seek(int64(n), io.SeekCurrent); err != nil {
+				return written, err
+			}
+		} else if _, err := sw.f.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// isZero will check if every byte of b is zero
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// copySparse will copy the data regions of src into tmp and leave holes in place of the
+// holes of src and of the zero blocks, tmp is then extended to the size of src
+func (ds *DirSync) copySparse(ctx context.Context, tmp, src *os.File, op Operation) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	regions, err := dataRegions(src, size)
+	if err != nil {
+		ds.Logger.Debug("hole detection unavailable, only zero blocks are skipped", "path", op.Src, "error", err)
+		regions = [][2]int64{{0, size}}
+	}
+
+	dst := &progressWriter{ctx: ctx, ds: ds, path: op.Src, w: &sparseWriter{f: tmp}}
+	offset := int64(0)
+	for _, r := range regions {
+		if r[0] > offset {
+			ds.emit(ctx, Event{Type: EventBytes, Path: op.Src, Bytes: r[0] - offset})
+		}
+		if _, err = src.Seek(r[0], io.SeekStart); err != nil {
+			return err
+		}
+		if _, err = tmp.Seek(r[0], io.SeekStart); err != nil {
+			return err
+		}
+		if _, err = ds.copyStream(ctx, dst, io.LimitReader(src, r[1]-r[0])); err != nil {
+			return err
+		}
+		offset = r[1]
+	}
+	if size > offset {
+		ds.emit(ctx, Event{Type: EventBytes, Path: op.Src, Bytes: size - offset})
+	}
+	// a trailing hole is only made by the file size
+	return tmp.Truncate(size)
+}
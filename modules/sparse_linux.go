@@ -0,0 +1,38 @@
+package dsync
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// whence values of lseek to find the data and the holes of a file
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// dataRegions will return the start and end offsets of the data regions of f using
+// SEEK_DATA and SEEK_HOLE, the file system may report the whole file as data
+func dataRegions(f *os.File, size int64) ([][2]int64, error) {
+	var regions [][2]int64
+	for offset := int64(0); offset < size; {
+		start, err := f.Seek(offset, seekData)
+		if err != nil {
+			if errors.Is(err, syscall.ENXIO) {
+				break // only a hole remains
+			}
+			return nil, err
+		}
+		end, err := f.Seek(start, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if end > size {
+			end = size
+		}
+		regions = append(regions, [2]int64{start, end})
+		offset = end
+	}
+	return regions, nil
+}
@@ -0,0 +1,42 @@
+package dsync
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDataRegions(t *testing.T) {
+	name := fmt.Sprintf("%s/%s", sourceDir, randomString(10))
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("error %v", err)
+	}
+	defer os.Remove(name)
+	defer f.Close()
+
+	const size = 4 * 1024 * 1024
+	if _, err = f.WriteAt([]byte("data"), size/2); err != nil || f.Truncate(size) != nil {
+		t.Fatalf("error %v", err)
+	}
+
+	regions, err := dataRegions(f, size)
+	if err != nil {
+		t.Fatalf("must be nil, got %v", err)
+	}
+	covered := false
+	for _, r := range regions {
+		if r[0] <= size/2 && size/2+4 <= r[1] {
+			covered = true
+		}
+	}
+	if !covered {
+		t.Errorf("data must be in a region, got %v", regions)
+	}
+	if len(regions) == 1 && regions[0] == [2]int64{0, size} {
+		t.Skip("file system reports no hole")
+	}
+	if regions[0][0] == 0 {
+		t.Errorf("leading hole must be skipped, got %v", regions)
+	}
+}
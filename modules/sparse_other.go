@@ -0,0 +1,15 @@
+//go:build !linux
+
+package dsync
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoHoleDetection = errors.New("SEEK_DATA and SEEK_HOLE are only used on linux")
+
+// dataRegions will fail as holes are not detected, the copy falls back to zero blocks
+func dataRegions(_ *os.File, _ int64) ([][2]int64, error) {
+	return nil, errNoHoleDetection
+}
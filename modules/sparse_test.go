@@ -0,0 +1,87 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestIsZero(t *testing.T) {
+	if !isZero(make([]byte, 10)) || !isZero(nil) {
+		t.Errorf("must be zero")
+	}
+	if isZero([]byte{0, 0, 1}) {
+		t.Errorf("must not be zero")
+	}
+}
+
+func TestDosyncSparse(t *testing.T) {
+	ctx := context.Background()
+	const size = 8 * 1024 * 1024
+
+	srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	if ensureDir(srcDir) != nil {
+		t.Errorf("error")
+	}
+	defer os.RemoveAll(srcDir)
+
+	// holes around a single data block, plus a block of zeros written explicitly
+	disk := fmt.Sprintf("%s/%s", srcDir, "disk.img")
+	f, err := os.Create(disk)
+	if err != nil {
+		t.Fatalf("error %v", err)
+	}
+	if _, err = f.WriteAt([]byte("data"), size/2); err != nil {
+		t.Fatalf("error %v", err)
+	}
+	if _, err = f.WriteAt(make([]byte, 64*1024), size/4); err != nil {
+		t.Fatalf("error %v", err)
+	}
+	if err = f.Truncate(size); err != nil {
+		t.Fatalf("error %v", err)
+	}
+	f.Close()
+
+	for _, tt := range []struct {
+		name   string
+		sparse bool
+	}{
+		{"success holes are kept", true},
+		{"success holes are filled by default", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+			if ensureDir(dstDir) != nil {
+				t.Errorf("error")
+			}
+			defer os.RemoveAll(dstDir)
+
+			ds, err := New(ctx, srcDir, dstDir, WithSparse(tt.sparse))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			if err = ds.DoSync(ctx); err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+
+			want, _ := os.ReadFile(disk)
+			got, err := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "disk.img"))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("content must be equal")
+			}
+
+			summary := ds.GetSummary()
+			if summary.Bytes != size {
+				t.Errorf("apparent size must be %d, got %d", size, summary.Bytes)
+			}
+			if tt.sparse && summary.Allocated >= size/2 {
+				t.Errorf("allocated size must be small, got %d", summary.Allocated)
+			}
+			if !tt.sparse && summary.Allocated < size {
+				t.Errorf("allocated size must be the full size, got %d", summary.Allocated)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package dsync
+
+import (
+	"context"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultBufferSize = 128 * 1024
+	DefaultMaxMemory  = 64 * 1024 * 1024
+	// TempMarker is part of the name of the temporary files written before being
+	// renamed over their target, a leftover means the copy was interrupted
+	TempMarker = ".sync-tmp-"
+)
+
+// bufferPool hands out fixed size buffers and blocks when the total size of
+// the buffers in flight would exceed the memory limit
+type bufferPool struct {
+	size   int
+	pool   sync.Pool
+	tokens chan struct{}
+	// reserving is held while the tokens of a reservation are taken one by one, so two
+	// reservations never wait on each other with part of the tokens
+	reserving sync.Mutex
+}
+
+func newBufferPool(size int, maxMemory int64) *bufferPool {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	count := maxMemory / int64(size)
+	if count < 1 {
+		count = 1 // always allow at least one buffer
+	}
+
+	bp := &bufferPool{
+		size:   size,
+		tokens: make(chan struct{}, count),
+	}
+	bp.pool.New = func() interface{} {
+		buf := make([]byte, bp.size)
+		return &buf
+	}
+	return bp
+}
+
+// get will wait until a buffer is available, a caller must never hold more than one
+// buffer at a time otherwise it may wait forever
+func (bp *bufferPool) get(ctx context.Context) (*[]byte, error) {
+	select {
+	case bp.tokens <- struct{}{}:
+	case <-ctx.Done():
+		return nil, dsyncerr.ErrSyncCanceled
+	}
+	return bp.pool.Get().(*[]byte), nil
+}
+
+func (bp *bufferPool) put(buf *[]byte) {
+	bp.pool.Put(buf)
+	<-bp.tokens
+}
+
+// reserve will wait until n bytes allocated outside the pool fit in the memory limit, one
+// reservation larger than the limit takes it all, release must be called once they are freed
+func (bp *bufferPool) reserve(ctx context.Context, n int64) (release func(), err error) {
+	count := int((n + int64(bp.size) - 1) / int64(bp.size))
+	if count < 1 {
+		count = 1
+	}
+	if count > cap(bp.tokens) {
+		count = cap(bp.tokens)
+	}
+
+	bp.reserving.Lock()
+	defer bp.reserving.Unlock()
+	release = func() {
+		for i := 0; i < count; i++ {
+			<-bp.tokens
+		}
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case bp.tokens <- struct{}{}:
+		case <-ctx.Done():
+			count = i
+			release()
+			return nil, dsyncerr.ErrSyncCanceled
+		}
+	}
+	return release, nil
+}
+
+// ctxReader stops reading as soon as the context is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if cr.ctx.Err() != nil {
+		return 0, dsyncerr.ErrSyncCanceled
+	}
+	return cr.r.Read(p)
+}
+
+// copyStream will copy src into dst through a pooled buffer, wrapping both ends
+// hides ReaderFrom/WriterTo so io.CopyBuffer always uses the given buffer
+func (ds *DirSync) copyStream(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	buf, err := ds.buffers.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer ds.buffers.put(buf)
+
+	return io.CopyBuffer(struct{ io.Writer }{dst}, &ctxReader{ctx, src}, *buf)
+}
+
+// Checksum will compute the sum of a file with the configured hasher without loading it fully in memory
+func (ds *DirSync) Checksum(ctx context.Context, fileName string) ([]byte, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		if errClose := f.Close(); errClose != nil {
+			ds.Logger.Warn("fail close file", "path", fileName, "error", errClose)
+		}
+	}(file)
+
+	h := ds.Hasher.New()
+	if _, err = ds.copyStream(ctx, h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// copyFile will stream the content of op.Src into a temporary file in the same directory
+// as op.Dst then rename it over op.Dst, so op.Dst is either the old or the new content
+func (ds *DirSync) copyFile(ctx context.Context, op Operation) error {
+	dstName := op.Dst
+	src, err := os.Open(op.Src)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		if errClose := f.Close(); errClose != nil {
+			ds.Logger.Warn("fail close file", "path", op.Src, "error", errClose)
+		}
+	}(src)
+
+	var tmp *os.File
+	var offset int64
+	if ds.Resume {
+		tmp, offset, err = ds.openPartial(ctx, src, op)
+	} else {
+		tmp, err = os.CreateTemp(filepath.Dir(dstName), "."+filepath.Base(dstName)+TempMarker+"*")
+	}
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	err = ds.writeTemp(ctx, tmp, src, op, offset)
+	if err != nil && ds.Resume {
+		// the bytes written so far are kept for the next attempt
+		ds.keepPartial(tmp, op)
+		if errClose := tmp.Close(); errClose != nil {
+			ds.Logger.Warn("fail close file", "path", tmpName, "error", errClose)
+		}
+		return err
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil && ds.PreserveTimes {
+		// times are set once the file is closed, a later write would change them
+		err = os.Chtimes(tmpName, op.AccessTime, op.ModTime)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, dstName)
+	}
+	if err != nil {
+		if errRemove := os.Remove(tmpName); errRemove != nil && !os.IsNotExist(errRemove) {
+			ds.Logger.Warn("fail remove temporary file", "path", tmpName, "error", errRemove)
+		}
+	}
+	if ds.Resume {
+		_, recordName := partialNames(dstName)
+		if errRemove := os.Remove(recordName); errRemove != nil && !os.IsNotExist(errRemove) {
+			ds.Logger.Warn("fail remove partial record", "path", recordName, "error", errRemove)
+		}
+	}
+	return err
+}
+
+// createInPlace will create a new entry named like a temporary file next to dst with
+// create, then rename it over dst
+func (ds *DirSync) createInPlace(dst string, create func(tmpName string) error) error {
+	tmpName := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s%s%d", filepath.Base(dst), TempMarker, rand.Int63()))
+	if err := create(tmpName); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, dst); err != nil {
+		if errRemove := os.Remove(tmpName); errRemove != nil {
+			ds.Logger.Warn("fail remove temporary file", "path", tmpName, "error", errRemove)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeTemp will fill the temporary file from offset and flush it to disk if fsync is enabled,
+// a resumed file is only appended to so the delta and sparse copies are not used
+func (ds *DirSync) writeTemp(ctx context.Context, tmp *os.File, src io.Reader, op Operation, offset int64) error {
+	if err := tmp.Chmod(ds.fileMode(op)); err != nil {
+		return err
+	}
+	done := false
+	if ds.Delta && op.Kind == OpUpdate && offset == 0 {
+		var err error
+		if done, err = ds.copyDelta(ctx, tmp, src, op); err != nil {
+			return err
+		}
+	}
+	switch f, ok := src.(*os.File); {
+	case done:
+	case ok && ds.Sparse && offset == 0:
+		if err := ds.copySparse(ctx, tmp, f, op); err != nil {
+			return err
+		}
+	default:
+		dst := &progressWriter{ctx: ctx, ds: ds, path: op.Src, w: tmp}
+		if _, err := ds.copyStream(ctx, dst, src); err != nil {
+			return err
+		}
+	}
+	if ds.Fsync {
+		return tmp.Sync()
+	}
+	return nil
+}
+
+// fileMode will return the mode of a copied file, the source mode when it is preserved
+func (ds *DirSync) fileMode(op Operation) fs.FileMode {
+	if ds.PreservePerms {
+		return op.Mode.Perm()
+	}
+	return 0755
+}
+
+// isTempFile will check if name is a temporary file left by an interrupted copy
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, TempMarker)
+}
+
+// cleanTempFiles will remove the temporary files left in destination by interrupted runs,
+// the partial files and their records as well when resume is off
+func (ds *DirSync) cleanTempFiles(ctx context.Context) error {
+	return filepath.WalkDir(ds.AbsDstRoot, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return dsyncerr.ErrSyncCanceled
+		}
+		if err != nil {
+			ds.Logger.Warn("fail walk, will be skipped", "path", path, "error", err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, partial := partialTarget(d.Name()); !isTempFile(d.Name()) && (ds.Resume || !partial) {
+			return nil
+		}
+
+		if errRemove := os.Remove(path); errRemove != nil {
+			ds.Logger.Warn("fail remove stale temporary file", "path", path, "error", errRemove)
+			return nil
+		}
+		ds.Logger.Info("stale temporary file removed", "path", path)
+		return nil
+	})
+}
@@ -0,0 +1,206 @@
+package dsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBufferPool(t *testing.T) {
+	t.Run("success limit buffers in flight", func(t *testing.T) {
+		bp := newBufferPool(16, 32)
+
+		first, err := bp.get(context.Background())
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		second, err := bp.get(context.Background())
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if len(*first) != 16 || len(*second) != 16 {
+			t.Errorf("must be 16 bytes")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = bp.get(ctx)
+		if !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("third buffer must wait until canceled")
+		}
+
+		bp.put(first)
+		third, err := bp.get(context.Background())
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		bp.put(second)
+		bp.put(third)
+	})
+
+	t.Run("success at least one buffer", func(t *testing.T) {
+		bp := newBufferPool(16, 0)
+		buf, err := bp.get(context.Background())
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		bp.put(buf)
+	})
+
+	t.Run("success reservation counted against the limit", func(t *testing.T) {
+		bp := newBufferPool(16, 64)
+
+		// larger than the limit, it takes every buffer
+		release, err := bp.reserve(context.Background(), 1000)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err = bp.get(ctx); !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("buffer must wait until the reservation is released")
+		}
+		if _, err = bp.reserve(ctx, 1); !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("reservation must wait until the reservation is released")
+		}
+
+		release()
+		if len(bp.tokens) != 0 {
+			t.Errorf("every buffer must be released, %d in flight", len(bp.tokens))
+		}
+		release, err = bp.reserve(context.Background(), 20)
+		if err != nil || len(bp.tokens) != 2 {
+			t.Errorf("must take 2 buffers, got %d", len(bp.tokens))
+		}
+		release()
+	})
+}
+
+func TestCopyFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success copy with small buffers", func(t *testing.T) {
+		content := randomString(1000)
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		writeFile(targetSrc, content)
+		targetDst := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(targetSrc, targetDst)
+
+		impl, err := New(ctx, sourceDir, destinationDir, WithBufferSize(7), WithMaxMemory(7))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		ds := impl.(*DirSync)
+		if err = ds.copyFile(ctx, Operation{Kind: OpCopy, Src: targetSrc, Dst: targetDst}); err != nil {
+			t.Errorf("must be nil")
+		}
+		data, _ := os.ReadFile(targetDst)
+		if string(data) != content {
+			t.Errorf("content must be equal")
+		}
+
+		sumSrc, err := ds.Checksum(ctx, targetSrc)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		sumDst, err := ds.Checksum(ctx, targetDst)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if !bytes.Equal(sumSrc, sumDst) {
+			t.Errorf("sum must be equal")
+		}
+	})
+
+	t.Run("fail canceled context", func(t *testing.T) {
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		writeFile(targetSrc, randomString(100))
+		targetDst := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(targetSrc, targetDst)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		impl, err := New(cctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		ds := impl.(*DirSync)
+		err = ds.copyFile(cctx, Operation{Kind: OpCopy, Src: targetSrc, Dst: targetDst})
+		if !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("err must be %s", dsyncerr.ErrSyncCanceled)
+		}
+	})
+}
+
+func TestAtomicCopy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success stale temporary file removed", func(t *testing.T) {
+		stale := fmt.Sprintf("%s/.%s%s%s", destinationDir, "hello", TempMarker, "123")
+		writeFile(stale, "partial")
+		defer func(s string) {
+			os.RemoveAll(s)
+		}(stale)
+
+		ds, err := New(ctx, sourceDir, destinationDir, WithFsync(true))
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		err = ds.DoSync(ctx)
+		if err != nil {
+			t.Errorf("must be nil")
+		}
+		if ds.IsFileExist(stale) {
+			t.Errorf("stale temporary file must be removed")
+		}
+	})
+
+	t.Run("fail canceled copy keeps destination", func(t *testing.T) {
+		targetSrc := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		writeFile(targetSrc, "new content")
+		targetDst := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		writeFile(targetDst, "old")
+
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(targetSrc, targetDst)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		impl, err := New(ctx, sourceDir, destinationDir)
+		if err != nil {
+			t.Errorf("fail test")
+		}
+		ds := impl.(*DirSync)
+		err = ds.copyFile(cctx, Operation{Kind: OpCopy, Src: targetSrc, Dst: targetDst})
+		if !errors.Is(err, dsyncerr.ErrSyncCanceled) {
+			t.Errorf("err must be %s", dsyncerr.ErrSyncCanceled)
+		}
+		data, _ := os.ReadFile(targetDst)
+		if string(data) != "old" {
+			t.Errorf("destination must not be touched")
+		}
+		entries, _ := os.ReadDir(destinationDir)
+		for _, e := range entries {
+			if isTempFile(e.Name()) {
+				t.Errorf("temporary file must be removed")
+			}
+		}
+	})
+}
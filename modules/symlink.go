@@ -0,0 +1,102 @@
+package dsync
+
+import (
+	"context"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkMode tells how symbolic links found in source are synced
+type SymlinkMode string
+
+const (
+	SymlinkFollow SymlinkMode = "follow" // sync the content the link points to, link loops are skipped
+	SymlinkCopy   SymlinkMode = "copy"   // recreate the link with the same target in destination
+	SymlinkSkip   SymlinkMode = "skip"   // ignore links
+	SymlinkSafe   SymlinkMode = "safe"   // follow only the links whose target stays inside the source root
+)
+
+// SymlinkModeByName will return the mode named follow, copy, skip or safe
+func SymlinkModeByName(name string) (SymlinkMode, error) {
+	switch mode := SymlinkMode(name); mode {
+	case SymlinkFollow, SymlinkCopy, SymlinkSkip, SymlinkSafe:
+		return mode, nil
+	}
+	return "", dsyncerr.ErrUnknownSymlinkMode
+}
+
+// isSymlink will check if a walked entry is a symbolic link
+func isSymlink(d fs.DirEntry) bool {
+	return d.Type()&fs.ModeSymlink != 0
+}
+
+// followSymlink will resolve the link found at path and visit its target under the
+// path of the link, a directory target is walked with visit as if it was in place
+func (ds *DirSync) followSymlink(ctx context.Context, path string, visit fs.WalkDirFunc) error {
+	if ds.Symlinks == SymlinkSkip {
+		ds.Logger.Debug("symlink, will be skipped", "path", path)
+		ds.skip(ctx, path, nil)
+		return nil
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("%w: %s", dsyncerr.ErrDanglingSymlink, path)
+		}
+		ds.Logger.Warn("fail resolve symlink, will be skipped", "path", path, "error", err)
+		ds.skip(ctx, path, err)
+		return nil
+	}
+	if ds.Symlinks == SymlinkSafe {
+		root, errRoot := filepath.EvalSymlinks(ds.AbsSrcRoot)
+		if errRoot != nil || !isInside(target, root) {
+			ds.Logger.Info("symlink points outside of source, will be skipped", "path", path, "target", target)
+			ds.skip(ctx, path, nil)
+			return nil
+		}
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		ds.Logger.Warn("fail stat symlink target, will be skipped", "path", path, "error", err)
+		ds.skip(ctx, path, err)
+		return nil
+	}
+	if !info.IsDir() {
+		return visit(path, fs.FileInfoToDirEntry(info), nil)
+	}
+	if ds.isSymlinkLoop(path, target) {
+		ds.Logger.Warn("symlink loop, will be skipped", "path", path, "target", target)
+		ds.skip(ctx, path, nil)
+		return nil
+	}
+
+	return filepath.WalkDir(target, func(p string, d fs.DirEntry, err error) error {
+		return visit(path+strings.TrimPrefix(p, target), d, err)
+	})
+}
+
+// isSymlinkLoop will check if following the link at path walks again a directory
+// being walked, that is if target is the real location of an ancestor of the link
+func (ds *DirSync) isSymlinkLoop(path, target string) bool {
+	dir := ds.AbsSrcRoot
+	elems := strings.Split(ds.relPath(ds.AbsSrcRoot, filepath.Dir(path)), "/")
+	for i := -1; i < len(elems); i++ {
+		if i >= 0 && elems[i] != "" {
+			dir = filepath.Join(dir, elems[i])
+		}
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if real == target || isInside(real, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package dsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dsyncerr "github.com/bondhan/sync/modules/errors"
+	"os"
+	"testing"
+)
+
+func TestSymlinkModeByName(t *testing.T) {
+	t.Run("success known modes", func(t *testing.T) {
+		for _, name := range []string{"follow", "copy", "skip", "safe"} {
+			mode, err := SymlinkModeByName(name)
+			if err != nil || string(mode) != name {
+				t.Errorf("must be %s, got %s %v", name, mode, err)
+			}
+		}
+	})
+
+	t.Run("fail unknown mode", func(t *testing.T) {
+		if _, err := SymlinkModeByName("hard"); !errors.Is(err, dsyncerr.ErrUnknownSymlinkMode) {
+			t.Errorf("must be ErrUnknownSymlinkMode, got %v", err)
+		}
+	})
+}
+
+// symlinkTree will create in srcDir a file, a directory and links to both, a dangling link,
+// a link to a directory outside of srcDir and a link looping to srcDir
+func symlinkTree(t *testing.T, srcDir, outDir string) {
+	if ensureDir(fmt.Sprintf("%s/%s", srcDir, "dir")) != nil || ensureDir(outDir) != nil {
+		t.Errorf("error")
+	}
+	writeFile(fmt.Sprintf("%s/%s", srcDir, "file"), "hello")
+	writeFile(fmt.Sprintf("%s/%s", srcDir, "dir/inner"), "inner")
+	writeFile(fmt.Sprintf("%s/%s", outDir, "out"), "out")
+	links := map[string]string{
+		"linkfile": "file",
+		"linkdir":  "dir",
+		"dangling": "missing",
+		"outside":  outDir,
+		"dir/loop": "..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, fmt.Sprintf("%s/%s", srcDir, name)); err != nil {
+			t.Errorf("error %v", err)
+		}
+	}
+}
+
+func TestDosyncSymlinks(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		mode     SymlinkMode
+		links    map[string]string // destination links and their target, empty for the outside directory
+		files    []string          // destination regular files
+		missing  []string          // destination paths which must not exist
+		dangling bool              // the dangling link is reported as a failure
+	}{
+		{
+			mode:  SymlinkCopy,
+			links: map[string]string{"linkfile": "file", "linkdir": "dir", "dangling": "missing", "outside": "", "dir/loop": ".."},
+			files: []string{"file", "dir/inner"},
+		},
+		{
+			mode:     SymlinkFollow,
+			files:    []string{"file", "linkfile", "linkdir/inner", "outside/out"},
+			missing:  []string{"dangling", "dir/loop", "linkdir/loop"},
+			dangling: true,
+		},
+		{
+			mode:    SymlinkSkip,
+			files:   []string{"file", "dir/inner"},
+			missing: []string{"linkfile", "linkdir", "dangling", "outside", "dir/loop"},
+		},
+		{
+			mode:     SymlinkSafe,
+			files:    []string{"file", "linkfile", "linkdir/inner"},
+			missing:  []string{"dangling", "outside", "dir/loop"},
+			dangling: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+			dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+			outDir := fmt.Sprintf("%s/%s", rootDir, randomString(10))
+			if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+				t.Errorf("error")
+			}
+			defer func(s, d, o string) {
+				os.RemoveAll(s)
+				os.RemoveAll(d)
+				os.RemoveAll(o)
+			}(srcDir, dstDir, outDir)
+			symlinkTree(t, srcDir, outDir)
+
+			ds, err := New(ctx, srcDir, dstDir, WithSymlinks(tt.mode), WithErrorPolicy(ContinueOnError))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			err = ds.DoSync(ctx)
+			if tt.dangling != errors.Is(err, dsyncerr.ErrDanglingSymlink) {
+				t.Errorf("dangling link must be reported %v, got %v", tt.dangling, err)
+			}
+			if !tt.dangling && err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+
+			for name, target := range tt.links {
+				if target == "" {
+					target = outDir
+				}
+				if got, errLink := os.Readlink(fmt.Sprintf("%s/%s", dstDir, name)); errLink != nil || got != target {
+					t.Errorf("%s must link to %s, got %s %v", name, target, got, errLink)
+				}
+			}
+			for _, name := range tt.files {
+				info, errStat := os.Lstat(fmt.Sprintf("%s/%s", dstDir, name))
+				if errStat != nil || !info.Mode().IsRegular() {
+					t.Errorf("%s must be a regular file, got %v", name, errStat)
+				}
+			}
+			for _, name := range tt.missing {
+				if _, errStat := os.Lstat(fmt.Sprintf("%s/%s", dstDir, name)); !os.IsNotExist(errStat) {
+					t.Errorf("%s must not exist", name)
+				}
+			}
+			if got := ds.GetSummary().Symlinks; got != int64(len(tt.links)) {
+				t.Errorf("must create %d links, got %d", len(tt.links), got)
+			}
+		})
+	}
+
+	t.Run("success unchanged links are kept", func(t *testing.T) {
+		srcDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+		dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+		if ensureDir(srcDir) != nil || ensureDir(dstDir) != nil {
+			t.Errorf("error")
+		}
+		defer func(s, d string) {
+			os.RemoveAll(s)
+			os.RemoveAll(d)
+		}(srcDir, dstDir)
+		writeFile(fmt.Sprintf("%s/%s", srcDir, "file"), "hello")
+		if os.Symlink("file", fmt.Sprintf("%s/%s", srcDir, "link")) != nil {
+			t.Errorf("error")
+		}
+
+		for i, want := range []int64{1, 0} {
+			ds, err := New(ctx, srcDir, dstDir, WithSymlinks(SymlinkCopy))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			if err = ds.DoSync(ctx); err != nil {
+				t.Errorf("must be nil")
+			}
+			if got := ds.GetSummary().Symlinks; got != want {
+				t.Errorf("run %d must create %d links, got %d", i, want, got)
+			}
+		}
+	})
+}
+
+func TestDosyncSymlinkRoot(t *testing.T) {
+	ctx := context.Background()
+
+	realDir := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	if ensureDir(realDir) != nil || ensureDir(fmt.Sprintf("%s/%s", realDir, "sub")) != nil {
+		t.Errorf("error")
+	}
+	defer os.RemoveAll(realDir)
+	writeFile(fmt.Sprintf("%s/%s", realDir, "sub/file"), "hello")
+	link := fmt.Sprintf("%s/%s", sourceDir, randomString(5))
+	if err := os.Symlink(realDir, link); err != nil {
+		t.Fatalf("error %v", err)
+	}
+	defer os.Remove(link)
+
+	for _, mode := range []SymlinkMode{SymlinkFollow, SymlinkSkip, SymlinkSafe} {
+		t.Run(fmt.Sprintf("success source link walked with %s", mode), func(t *testing.T) {
+			dstDir := fmt.Sprintf("%s/%s", destinationDir, randomString(5))
+			if ensureDir(dstDir) != nil {
+				t.Errorf("error")
+			}
+			defer os.RemoveAll(dstDir)
+
+			ds, err := New(ctx, link, dstDir, WithSymlinks(mode))
+			if err != nil {
+				t.Errorf("fail test")
+			}
+			if err = ds.DoSync(ctx); err != nil {
+				t.Errorf("must be nil, got %v", err)
+			}
+			data, err := os.ReadFile(fmt.Sprintf("%s/%s", dstDir, "sub/file"))
+			if err != nil || string(data) != "hello" {
+				t.Errorf("file below the source link must be copied")
+			}
+		})
+	}
+}